@@ -0,0 +1,80 @@
+package hfw
+
+//管理接口，Admin.Enable开启后才注册，没有开启的时候访问是404
+//Admin.Token不为空的时候，请求需要带上token
+//  curl -H 'X-Admin-Token: xxx' http://127.0.0.1:8080/cron/jobs
+//  curl -H 'X-Admin-Token: xxx' -d name=sync http://127.0.0.1:8080/cron/jobs
+//Admin.Token为空的时候只能GET查看，POST等修改操作返回403
+//内置/worker/status、/cron/jobs，引入db包的时候有/db/stats，其他的用RegisterAdminHandler注册，如
+//hfw.RegisterAdminHandler("/foo/status", fooStatus)
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+
+	logger "github.com/hsyan2008/go-logger"
+)
+
+var adminHandlers = struct {
+	l *sync.Mutex
+	//已经注册到http的时候，后面注册的直接注册到http
+	registered bool
+	list       map[string]http.HandlerFunc
+}{
+	l: &sync.Mutex{},
+	list: map[string]http.HandlerFunc{
+		"/worker/status": workerStatus,
+		"/cron/jobs":     cronAdmin,
+	},
+}
+
+//RegisterAdminHandler 注册管理接口，只有Admin.Enable开启的时候才能访问，并且需要Admin.Token
+func RegisterAdminHandler(pattern string, handler http.HandlerFunc) {
+	adminHandlers.l.Lock()
+	defer adminHandlers.l.Unlock()
+	adminHandlers.list[pattern] = handler
+	if adminHandlers.registered {
+		registerAdminHandler(pattern, handler)
+	}
+}
+
+//在routeInit的时候调用
+func initAdminHandlers() {
+	adminHandlers.l.Lock()
+	defer adminHandlers.l.Unlock()
+	adminHandlers.registered = true
+	for pattern, handler := range adminHandlers.list {
+		registerAdminHandler(pattern, handler)
+	}
+}
+
+func registerAdminHandler(pattern string, handler http.HandlerFunc) {
+	if !GetConfig().Admin.Enable {
+		return
+	}
+	logger.Info("register admin handler", pattern)
+	http.HandleFunc(pattern, adminAuth(handler))
+}
+
+func adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := GetConfig().Admin.Token
+		if len(token) == 0 {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "Admin.Token is required", http.StatusForbidden)
+				return
+			}
+		} else {
+			got := r.Header.Get("X-Admin-Token")
+			if len(got) == 0 {
+				got = r.FormValue("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				logger.Warn("admin handler", r.URL.Path, "invalid token from", r.RemoteAddr)
+				http.Error(w, "invalid admin token", http.StatusForbidden)
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
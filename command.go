@@ -0,0 +1,243 @@
+package hfw
+
+//子命令，Run按命令行参数执行对应的命令，没有命令的时候执行serve
+//全局参数(-e -v -set等)写在命令前面，命令自己的参数写在命令后面
+//  ./app -e prod serve -mode worker
+//  ./app -e prod migrate -dry-run up
+//  ./app help
+//用法
+//var dryRun bool
+//hfw.RegisterCommand(hfw.Command{
+//	Name:  "migrate",
+//	Short: "migrate database schema",
+//	Usage: "[flags] up|down",
+//	Flags: func(fs *flag.FlagSet) { fs.BoolVar(&dryRun, "dry-run", false, "only print sql") },
+//	Run:   func(args []string) error { ... },
+//})
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//Command 子命令
+type Command struct {
+	//多级命令用空格分隔，如"config check"
+	Name string
+	//一行说明，help里显示
+	Short string
+	//参数说明，如"[flags] name"
+	Usage string
+	//定义命令自己的参数
+	Flags func(fs *flag.FlagSet)
+	//args是解析完参数后剩下的
+	Run func(args []string) error
+
+	//内置的不需要加载配置或者自己加载配置的命令，在init里执行后退出
+	early bool
+}
+
+var commands = struct {
+	l    *sync.RWMutex
+	list []*Command
+}{
+	l: new(sync.RWMutex),
+}
+
+//RegisterCommand 注册子命令，必须在Run之前调用
+func RegisterCommand(c Command) error {
+	if len(strings.Fields(c.Name)) == 0 {
+		return errors.New("command name is empty")
+	}
+	if c.Run == nil {
+		return fmt.Errorf("command %s run is nil", c.Name)
+	}
+	c.Name = strings.Join(strings.Fields(c.Name), " ")
+
+	commands.l.Lock()
+	defer commands.l.Unlock()
+	for _, v := range commands.list {
+		if v.Name == c.Name {
+			return fmt.Errorf("command %s has registered", c.Name)
+		}
+	}
+	commands.list = append(commands.list, &c)
+
+	return nil
+}
+
+//按最长的名字匹配命令，没有参数的时候是serve
+func findCommand(args []string) (cmd *Command, rest []string, err error) {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	commands.l.RLock()
+	defer commands.l.RUnlock()
+	for _, c := range commands.list {
+		words := strings.Split(c.Name, " ")
+		if len(words) > len(args) || (cmd != nil && len(words) <= len(strings.Split(cmd.Name, " "))) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == c.Name {
+			cmd = c
+			rest = args[len(words):]
+		}
+	}
+	if cmd == nil {
+		return nil, nil, fmt.Errorf("unknown command %q, run `%s help` for usage", args[0], os.Args[0])
+	}
+
+	return
+}
+
+func (c *Command) run(args []string) error {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [global flags] %s %s\n\n%s\n", os.Args[0], c.Name, c.Usage, c.Short)
+		fs.PrintDefaults()
+	}
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	return c.Run(fs.Args())
+}
+
+func printCommands(w io.Writer) {
+	commands.l.RLock()
+	list := make([]*Command, len(commands.list))
+	copy(list, commands.list)
+	commands.l.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	fmt.Fprintf(w, "Usage: %s [global flags] [command] [command flags] [args]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range list {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Short)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\nRun `%s help command` for command flags.\n\nGlobal flags:\n", os.Args[0])
+}
+
+//config check和config print用，只读取和检查配置，不连接redis等
+//配置有问题的时候输出所有问题并退出
+func readConfigOnly() (c configs.AllConfig, err error) {
+	if _, err = initEnvironment(); err != nil {
+		return
+	}
+	//readConfig最后会调用Check
+	c, _, err = readConfig()
+	if _, ok := err.(configs.CheckErrors); ok {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	return
+}
+
+func registerBuiltinCommands() {
+	_ = RegisterCommand(Command{
+		Name:  "serve",
+		Short: "start http server and workers, default command",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&runMode, "mode", runMode, "run mode, all: http and workers, http: only http, worker: only workers")
+		},
+		Run: func(args []string) error {
+			return runServe()
+		},
+	})
+	_ = RegisterCommand(Command{
+		Name:  "routes",
+		Short: "print registered routes",
+		Run: func(args []string) error {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ROUTE\tHANDLER\tPERMISSION")
+			for _, r := range getRoutes() {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", r.route, r.handler, r.permission)
+			}
+			return tw.Flush()
+		},
+	})
+	_ = RegisterCommand(Command{
+		Name:  "version",
+		Short: "print version",
+		early: true,
+		Run: func(args []string) error {
+			fmt.Printf("%s %s %s %s/%s\n", APPNAME, VERSION, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+			return nil
+		},
+	})
+	_ = RegisterCommand(Command{
+		Name:  "help",
+		Short: "print commands or command flags",
+		Usage: "[command]",
+		Run: func(args []string) error {
+			if len(args) == 0 {
+				flag.Usage()
+				return nil
+			}
+			cmd, _, err := findCommand(args)
+			if err != nil {
+				return err
+			}
+			return cmd.run([]string{"-h"})
+		},
+	})
+	_ = RegisterCommand(Command{
+		Name:  "config check",
+		Short: "check the config, print all problems and exit non-zero if any",
+		early: true,
+		Run: func(args []string) error {
+			if _, err := readConfigOnly(); err != nil {
+				return err
+			}
+			fmt.Println("config check ok")
+			return nil
+		},
+	})
+	_ = RegisterCommand(Command{
+		Name:  "config print",
+		Short: "print the effective config with secrets masked",
+		early: true,
+		Run: func(args []string) error {
+			c, err := readConfigOnly()
+			if err != nil {
+				return err
+			}
+			Config = c
+			return PrintConfig(os.Stdout)
+		},
+	})
+	for _, name := range []string{"encrypt", "decrypt"} {
+		encrypt := name == "encrypt"
+		for _, prefix := range []string{"", "config "} {
+			_ = RegisterCommand(Command{
+				Name:  prefix + name,
+				Short: name + " config values, read lines from stdin if no value",
+				Usage: "[value ...]",
+				early: true,
+				Run: func(args []string) error {
+					return runSecretCommand(encrypt, args, os.Stdin, os.Stdout)
+				},
+			})
+		}
+	}
+}
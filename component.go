@@ -0,0 +1,255 @@
+package hfw
+
+//组件的生命周期管理
+//Run启动http之前按依赖顺序启动组件，关闭时等http请求结束后按相反顺序停止
+//用法
+//err := hfw.GetSignalContext().RegisterComponent(hfw.Component{
+//	Name:      "consumer",
+//	DependsOn: []string{"db"},
+//	Run:       func(ctx context.Context) error { ... <-ctx.Done() ... },
+//})
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+)
+
+//DefaultStopTimeout 组件默认的停止超时
+const DefaultStopTimeout = 10 * time.Second
+
+//Component 随服务启动和停止的组件，OnStart、OnStop、Run至少设置一个
+type Component struct {
+	Name string
+	//依赖的组件，依赖的会先启动，后停止
+	DependsOn []string
+	//启动，不能阻塞，返回错误会中止启动
+	OnStart func(ctx context.Context) error
+	//停止，ctx在StopTimeout后超时
+	OnStop func(ctx context.Context) error
+	//长期运行的任务，OnStart之后在goroutine里执行，停止时ctx被取消，并等待返回
+	Run func(ctx context.Context) error
+	//停止超时，默认DefaultStopTimeout
+	StopTimeout time.Duration
+}
+
+type component struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type componentRegistry struct {
+	mu *sync.Mutex
+	//按注册顺序
+	list []*component
+	//按启动顺序
+	started   []*component
+	isStarted bool
+	isStopped bool
+}
+
+func newComponentRegistry() *componentRegistry {
+	return &componentRegistry{
+		mu: new(sync.Mutex),
+	}
+}
+
+//RegisterComponent 注册组件，如果组件已经启动过，新注册的组件会立即启动
+func (ctx *SignalContext) RegisterComponent(c Component) (err error) {
+	if len(c.Name) == 0 {
+		return errors.New("component name is empty")
+	}
+	if c.OnStart == nil && c.OnStop == nil && c.Run == nil {
+		return fmt.Errorf("component %s has nothing to do", c.Name)
+	}
+	if c.StopTimeout <= 0 {
+		c.StopTimeout = DefaultStopTimeout
+	}
+
+	r := ctx.components
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isStopped {
+		return fmt.Errorf("component %s register after stopped", c.Name)
+	}
+	for _, v := range r.list {
+		if v.Name == c.Name {
+			return fmt.Errorf("component %s has registered", c.Name)
+		}
+	}
+	comp := &component{Component: c}
+	if r.isStarted {
+		for _, name := range c.DependsOn {
+			if !r.isRunning(name) {
+				return fmt.Errorf("component %s depends on %s which is not started", c.Name, name)
+			}
+		}
+		if err = r.start(ctx.Ctx, comp); err != nil {
+			return
+		}
+	}
+	r.list = append(r.list, comp)
+
+	return
+}
+
+//按依赖顺序启动所有组件，有一个失败，已启动的会按相反顺序停止
+func (ctx *SignalContext) startComponents() (err error) {
+	r := ctx.components
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isStarted {
+		return
+	}
+	r.isStarted = true
+
+	list, err := r.sort()
+	if err != nil {
+		return
+	}
+	for _, comp := range list {
+		if err = r.start(ctx.Ctx, comp); err != nil {
+			r.stop(nil)
+			return
+		}
+	}
+
+	return
+}
+
+//按启动的相反顺序停止组件
+//w不为nil的时候，未停止的组件会记录在w里，关闭超时的时候可以输出
+func (ctx *SignalContext) stopComponents(w *works) {
+	r := ctx.components
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stop(w)
+}
+
+func (r *componentRegistry) start(parent context.Context, comp *component) (err error) {
+	logger.Infof("component %s starting", comp.Name)
+	if comp.OnStart != nil {
+		if err = comp.OnStart(parent); err != nil {
+			return fmt.Errorf("component %s start failed: %v", comp.Name, err)
+		}
+	}
+	if comp.Run != nil {
+		var runCtx context.Context
+		runCtx, comp.cancel = context.WithCancel(parent)
+		comp.done = make(chan struct{})
+		go func() {
+			defer close(comp.done)
+			if err := comp.Run(runCtx); err != nil && err != context.Canceled {
+				logger.Errorf("component %s run failed: %v", comp.Name, err)
+			}
+		}()
+	}
+	r.started = append(r.started, comp)
+
+	return
+}
+
+func (r *componentRegistry) stop(w *works) {
+	if r.isStopped {
+		return
+	}
+	r.isStopped = true
+	dones := make([]func(), len(r.started))
+	for i, comp := range r.started {
+		dones[i] = func() {}
+		if w != nil {
+			dones[i] = w.add("component " + comp.Name)
+		}
+	}
+	for i := len(r.started) - 1; i >= 0; i-- {
+		comp := r.started[i]
+		logger.Infof("component %s stopping", comp.Name)
+		if err := comp.stop(); err != nil {
+			logger.Warnf("component %s stop failed: %v", comp.Name, err)
+		} else {
+			logger.Infof("component %s stopped", comp.Name)
+		}
+		dones[i]()
+	}
+}
+
+func (comp *component) stop() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), comp.StopTimeout)
+	defer cancel()
+
+	if comp.cancel != nil {
+		comp.cancel()
+		select {
+		case <-comp.done:
+		case <-ctx.Done():
+			return fmt.Errorf("wait run return timeout after %s", comp.StopTimeout)
+		}
+	}
+	if comp.OnStop != nil {
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- comp.OnStop(ctx)
+		}()
+		select {
+		case err = <-errChan:
+		case <-ctx.Done():
+			err = fmt.Errorf("timeout after %s", comp.StopTimeout)
+		}
+	}
+
+	return
+}
+
+func (r *componentRegistry) isRunning(name string) bool {
+	for _, comp := range r.started {
+		if comp.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+//拓扑排序，没有依赖关系的按注册顺序
+func (r *componentRegistry) sort() (list []*component, err error) {
+	m := make(map[string]*component, len(r.list))
+	for _, comp := range r.list {
+		m[comp.Name] = comp
+	}
+
+	//0未访问 1访问中 2已完成
+	state := make(map[string]int, len(r.list))
+	var visit func(comp *component) error
+	visit = func(comp *component) error {
+		switch state[comp.Name] {
+		case 1:
+			return fmt.Errorf("component %s has circular dependency", comp.Name)
+		case 2:
+			return nil
+		}
+		state[comp.Name] = 1
+		for _, name := range comp.DependsOn {
+			dep, ok := m[name]
+			if !ok {
+				return fmt.Errorf("component %s depends on %s which is not registered", comp.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[comp.Name] = 2
+		list = append(list, comp)
+		return nil
+	}
+	for _, comp := range r.list {
+		if err = visit(comp); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
@@ -0,0 +1,103 @@
+package hfw
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComponentOrder(t *testing.T) {
+	ctx := newSignalContext()
+	rec := new(orderRecorder)
+	for _, c := range []struct {
+		name string
+		deps []string
+	}{
+		{"consumer", []string{"db", "redis"}},
+		{"db", nil},
+		{"redis", []string{"db"}},
+	} {
+		name := c.name
+		err := ctx.RegisterComponent(Component{
+			Name:      name,
+			DependsOn: c.deps,
+			OnStart: func(context.Context) error {
+				rec.add("start " + name)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				rec.add("stop " + name)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ctx.startComponents(); err != nil {
+		t.Fatal(err)
+	}
+	w := newWorks()
+	ctx.stopComponents(w)
+
+	want := "start db,start redis,start consumer,stop consumer,stop redis,stop db"
+	if got := strings.Join(rec.list, ","); got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+	if len(w.outstanding()) != 0 {
+		t.Fatalf("all components stopped, got %v", w.outstanding())
+	}
+	if err := ctx.RegisterComponent(Component{Name: "late", OnStart: func(context.Context) error { return nil }}); err == nil {
+		t.Fatal("register after stopped should fail")
+	}
+}
+
+func TestComponentStartFailed(t *testing.T) {
+	ctx := newSignalContext()
+	rec := new(orderRecorder)
+	_ = ctx.RegisterComponent(Component{
+		Name: "db",
+		Run: func(c context.Context) error {
+			<-c.Done()
+			rec.add("stop db")
+			return nil
+		},
+	})
+	_ = ctx.RegisterComponent(Component{
+		Name:      "consumer",
+		DependsOn: []string{"db"},
+		OnStart: func(context.Context) error {
+			return errors.New("connect failed")
+		},
+	})
+	if err := ctx.startComponents(); err == nil {
+		t.Fatal("want start error")
+	}
+	if strings.Join(rec.list, ",") != "stop db" {
+		t.Fatalf("started components should be stopped, got %v", rec.list)
+	}
+}
+
+func TestComponentStopTimeout(t *testing.T) {
+	ctx := newSignalContext()
+	block := make(chan struct{})
+	defer close(block)
+	_ = ctx.RegisterComponent(Component{
+		Name:        "hang",
+		StopTimeout: 100 * time.Millisecond,
+		Run: func(c context.Context) error {
+			<-block
+			return nil
+		},
+	})
+	if err := ctx.startComponents(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	ctx.stopComponents(nil)
+	if cost := time.Since(start); cost > time.Second {
+		t.Fatalf("stop should return after StopTimeout, cost %s", cost)
+	}
+}
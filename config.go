@@ -0,0 +1,307 @@
+package hfw
+
+//应用自定义配置
+//在config.toml里增加自己的table，如
+//[Order]
+//Timeout = "10s"
+//然后注册对应的struct，字段可以用`default:"..."`设置默认值
+//var orderConfig struct{ Timeout configs.Duration `default:"5s"` }
+//err := hfw.RegisterConfig("Order", &orderConfig)
+//
+//配置按以下顺序加载，后面的覆盖前面的
+//  config/config.toml
+//  config/<ENVIRONMENT>/config.toml
+//  config/<ENVIRONMENT>/config.local.toml
+//  HFW_开头的环境变量，如HFW_DB_PASSWORD，自定义配置如HFW_ORDER_TIMEOUT
+//  命令行参数，如-set Db.Password=xxx -set Order.Timeout=10s
+//值可以是ENC(...)格式的密文，见secret.go
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//每个配置文件解析出的table
+type configLayer struct {
+	meta  toml.MetaData
+	prims map[string]toml.Primitive
+}
+
+var configSections = struct {
+	l *sync.Mutex
+	//按加载顺序，后面的覆盖前面的
+	layers []*configLayer
+	//已注册的自定义配置
+	list map[string]interface{}
+}{
+	l:    &sync.Mutex{},
+	list: make(map[string]interface{}),
+}
+
+//configSetFlags 命令行参数-set，可以多次指定，如-set Db.Password=xxx -set Server.Concurrence=100
+type configSetFlags []string
+
+func (f *configSetFlags) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *configSetFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return errors.New("format must be key=value")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+var configSets configSetFlags
+
+//RegisterConfig 注册自定义配置，name为toml里的table名
+//v必须是struct的指针，先设置默认值，再从配置文件解析
+func RegisterConfig(name string, v interface{}) (err error) {
+	if len(name) == 0 {
+		return errors.New("config name is empty")
+	}
+	if isAllConfigField(name) {
+		return fmt.Errorf("config name %s is reserved", name)
+	}
+	if err = configs.SetDefaults(v); err != nil {
+		return
+	}
+
+	configSections.l.Lock()
+	defer configSections.l.Unlock()
+	if _, ok := configSections.list[name]; ok {
+		return fmt.Errorf("config %s has registered", name)
+	}
+
+	if err = decodeSection(configSections.layers, name, v); err != nil {
+		return
+	}
+	configSections.list[name] = v
+
+	return
+}
+
+//GetRegisteredConfig 获取已注册的自定义配置
+//配置重载后返回的是新解析的值，注册时传入的v不会被修改，需要重载的配置每次用这个获取
+func GetRegisteredConfig(name string) (v interface{}, ok bool) {
+	configSections.l.Lock()
+	defer configSections.l.Unlock()
+	v, ok = configSections.list[name]
+	return
+}
+
+func getConfigFiles() []string {
+	return []string{
+		filepath.Join(APPPATH, "config", "config.toml"),
+		filepath.Join(APPPATH, "config", ENVIRONMENT, "config.toml"),
+		filepath.Join(APPPATH, "config", ENVIRONMENT, "config.local.toml"),
+	}
+}
+
+//按顺序读取所有配置文件并合并，不修改全局的Config，加载和重载配置共用
+func readConfig() (c configs.AllConfig, layers []*configLayer, err error) {
+	configFiles := getConfigFiles()
+	for _, configPath := range configFiles {
+		if !common.IsExist(configPath) {
+			continue
+		}
+		meta, err := toml.DecodeFile(configPath, &c)
+		if err != nil {
+			return c, nil, fmt.Errorf("decode %s failed: %v", configPath, err)
+		}
+		warnUndecoded(meta)
+		layer, err := readConfigLayer(configPath)
+		if err != nil {
+			return c, nil, err
+		}
+		layers = append(layers, layer)
+	}
+	if len(layers) == 0 {
+		logger.Warnf("config file: %s not exist", configFiles[1])
+	}
+
+	if err = applyConfigOverrides(&c); err != nil {
+		return
+	}
+	if err = decryptConfig("", &c); err != nil {
+		return
+	}
+	formatConfig(&c)
+	err = c.Check(APPPATH)
+
+	return
+}
+
+//解析配置文件里的各个table，供RegisterConfig使用
+func readConfigLayer(configPath string) (layer *configLayer, err error) {
+	layer = &configLayer{prims: make(map[string]toml.Primitive)}
+	layer.meta, err = toml.DecodeFile(configPath, &layer.prims)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s failed: %v", configPath, err)
+	}
+
+	return
+}
+
+//依次解析各层配置文件，再用环境变量和命令行参数覆盖
+func decodeSection(layers []*configLayer, name string, v interface{}) (err error) {
+	var found bool
+	for _, layer := range layers {
+		prim, key, ok := layer.findSection(name)
+		if !ok {
+			continue
+		}
+		found = true
+		if err = layer.meta.PrimitiveDecode(prim, v); err != nil {
+			return fmt.Errorf("decode config %s failed: %v", name, err)
+		}
+		for _, k := range layer.meta.Undecoded() {
+			if len(k) > 1 && k[0] == key {
+				logger.Warnf("unknown config key: %s", k)
+			}
+		}
+	}
+	if !found {
+		logger.Warnf("config section: %s not exist, use default", name)
+	}
+
+	keys, err := configs.ApplyEnv(v, configs.EnvPrefix+strings.ToUpper(name)+"_", os.Environ())
+	if err != nil {
+		return
+	}
+	if len(keys) > 0 {
+		logger.Infof("config %s override by env: %v", name, keys)
+	}
+
+	if err = applyConfigSets(name, v); err != nil {
+		return
+	}
+
+	return decryptConfig(name, v)
+}
+
+//重新解析已注册的自定义配置，返回有修改的配置名
+//生成新的值替换，不修改原来的值，避免和读配置的goroutine冲突
+//有错误的时候不做任何修改
+func reloadSections(layers []*configLayer) (changed []string, err error) {
+	configSections.l.Lock()
+	defer configSections.l.Unlock()
+
+	list := make(map[string]interface{}, len(configSections.list))
+	for name, v := range configSections.list {
+		nv := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		if err = configs.SetDefaults(nv); err != nil {
+			return nil, fmt.Errorf("config %s: %v", name, err)
+		}
+		if err = decodeSection(layers, name, nv); err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(v, nv) {
+			list[name] = v
+			continue
+		}
+		list[name] = nv
+		changed = append(changed, name)
+	}
+	configSections.layers = layers
+	configSections.list = list
+	sort.Strings(changed)
+
+	return
+}
+
+//用环境变量和命令行参数覆盖AllConfig
+func applyConfigOverrides(c *configs.AllConfig) (err error) {
+	keys, err := configs.ApplyEnv(c, configs.EnvPrefix, os.Environ())
+	if err != nil {
+		return
+	}
+	if len(keys) > 0 {
+		logger.Infof("config override by env: %v", keys)
+	}
+
+	return applyConfigSets("", c)
+}
+
+//name为空表示AllConfig，否则是自定义配置的table名
+func applyConfigSets(name string, v interface{}) (err error) {
+	for _, kv := range configSets {
+		tmp := strings.SplitN(kv, "=", 2)
+		path := tmp[0]
+		section := strings.SplitN(path, ".", 2)[0]
+		if len(name) == 0 {
+			if !isAllConfigField(section) {
+				continue
+			}
+		} else {
+			if !strings.EqualFold(section, name) || !strings.Contains(path, ".") {
+				continue
+			}
+			path = path[len(section)+1:]
+		}
+		if err = configs.SetByPath(v, path, tmp[1]); err != nil {
+			return fmt.Errorf("-set %s: %v", kv, err)
+		}
+		logger.Infof("config override by flag: %s", tmp[0])
+	}
+
+	return
+}
+
+//PrintConfig 输出合并后的最终配置，密码等敏感信息已隐藏
+func PrintConfig(w io.Writer) error {
+	m := configs.Mask(Config, getDecryptedPaths("")...)
+	configSections.l.Lock()
+	for name, v := range configSections.list {
+		m[name] = configs.Mask(v, getDecryptedPaths(name)...)
+	}
+	configSections.l.Unlock()
+
+	return toml.NewEncoder(w).Encode(m)
+}
+
+//toml的key和struct字段一样，不区分大小写
+func (layer *configLayer) findSection(name string) (prim toml.Primitive, key string, ok bool) {
+	if prim, ok = layer.prims[name]; ok {
+		return prim, name, ok
+	}
+	for k, v := range layer.prims {
+		if strings.EqualFold(k, name) {
+			return v, k, true
+		}
+	}
+
+	return
+}
+
+func isAllConfigField(name string) bool {
+	rt := reflect.TypeOf(configs.AllConfig{})
+	for i := 0; i < rt.NumField(); i++ {
+		if strings.EqualFold(rt.Field(i).Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//只检查AllConfig里已有的table，其他table可能是自定义配置
+func warnUndecoded(meta toml.MetaData) {
+	for _, k := range meta.Undecoded() {
+		if len(k) > 0 && isAllConfigField(k[0]) {
+			logger.Warnf("unknown config key: %s", k)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package configs
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//DbDrivers 支持的数据库驱动
+var DbDrivers = []string{"mysql", "mssql", "sqlserver", "postgres", "postgresql", "pgsql", "sqlite", "sqlite3"}
+
+//DbPolicies 支持的从库负载均衡策略
+var DbPolicies = []string{"roundrobin", "random", "weightroundrobin", "weightrandom", "leastconn"}
+
+//CheckErrors 配置检查发现的所有问题
+type CheckErrors []string
+
+func (e CheckErrors) Error() string {
+	return fmt.Sprintf("config check failed, %d problems:\n  %s", len(e), strings.Join(e, "\n  "))
+}
+
+func (e *CheckErrors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+//Check 检查配置，一次返回所有问题，没有问题返回nil
+//appPath用于处理相对路径
+func (c AllConfig) Check(appPath string) error {
+	var errs CheckErrors
+
+	c.checkServer(&errs, appPath)
+	c.checkLogger(&errs)
+	c.checkDb(&errs)
+	c.checkRedis(&errs)
+
+	if len(c.Template.WidgetsPath) > 0 {
+		m, err := filepath.Glob(absPath(appPath, c.Template.WidgetsPath))
+		if err != nil || len(m) == 0 {
+			errs.add("Template.WidgetsPath: %s match no files", c.Template.WidgetsPath)
+		}
+	}
+
+	if len(c.Session.CookieName) > 0 {
+		if c.Session.CacheType != "redis" {
+			errs.add("Session.CacheType: %q unknown, must be redis", c.Session.CacheType)
+		}
+		if len(c.Redis.Server) == 0 {
+			errs.add("Session is enabled but Redis.Server is empty")
+		}
+	}
+
+	if c.Shutdown.HTTPTimeout < 0 || c.Shutdown.WorkerTimeout < 0 || c.Shutdown.CloseTimeout < 0 {
+		errs.add("Shutdown timeouts must not be negative")
+	}
+
+	if c.HotDeploy.Dep < 0 {
+		errs.add("HotDeploy.Dep: %d must not be negative", c.HotDeploy.Dep)
+	}
+	if c.HotDeploy.Debounce < 0 {
+		errs.add("HotDeploy.Debounce: %d must not be negative", c.HotDeploy.Debounce)
+	}
+	for _, p := range c.HotDeploy.Ignores {
+		if _, err := filepath.Match(p, ""); err != nil {
+			errs.add("HotDeploy.Ignores: %q invalid: %v", p, err)
+		}
+	}
+
+	for k := range c.ErrorMap {
+		if _, err := strconv.ParseInt(k, 10, 64); err != nil {
+			errs.add("ErrorMap: key %q must be integer", k)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (c AllConfig) checkServer(errs *CheckErrors, appPath string) {
+	s := c.Server
+	if len(s.Address) > 0 {
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			errs.add("Server.Address: %s invalid: %v", s.Address, err)
+		}
+	}
+	if s.ReadTimeout < 0 {
+		errs.add("Server.ReadTimeout: %d must not be negative", s.ReadTimeout)
+	}
+	if s.WriteTimeout < 0 {
+		errs.add("Server.WriteTimeout: %d must not be negative", s.WriteTimeout)
+	}
+	if s.UpgradeTimeout < 0 {
+		errs.add("Server.UpgradeTimeout: %d must not be negative", s.UpgradeTimeout)
+	}
+	if len(s.HTTPSCertFile) > 0 || len(s.HTTPSKeyFile) > 0 {
+		if len(s.HTTPSCertFile) == 0 || len(s.HTTPSKeyFile) == 0 {
+			errs.add("Server.HTTPSCertFile and Server.HTTPSKeyFile must be set together")
+		}
+		if len(s.HTTPSCertFile) > 0 && !isFile(absPath(appPath, s.HTTPSCertFile)) {
+			errs.add("Server.HTTPSCertFile: %s not exist", s.HTTPSCertFile)
+		}
+		if len(s.HTTPSKeyFile) > 0 && !isFile(absPath(appPath, s.HTTPSKeyFile)) {
+			errs.add("Server.HTTPSKeyFile: %s not exist", s.HTTPSKeyFile)
+		}
+	}
+}
+
+func (c AllConfig) checkLogger(errs *CheckErrors) {
+	l := c.Logger
+	if len(l.LogLevel) > 0 && !inList(strings.ToUpper(l.LogLevel), "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "OFF") {
+		errs.add("Logger.LogLevel: %q unknown, must be debug/info/warn/error/fatal/off", l.LogLevel)
+	}
+	if len(l.LogFile) == 0 {
+		return
+	}
+	switch strings.ToLower(l.LogType) {
+	case "daily":
+	case "roll":
+		if l.LogMaxNum <= 0 {
+			errs.add("Logger.LogMaxNum: %d must be positive when LogType is roll", l.LogMaxNum)
+		}
+		if l.LogSize <= 0 {
+			errs.add("Logger.LogSize: %d must be positive when LogType is roll", l.LogSize)
+		}
+		if len(l.LogUnit) > 0 && !inList(strings.ToUpper(l.LogUnit), "K", "KB", "M", "MB", "G", "GB", "T", "TB") {
+			errs.add("Logger.LogUnit: %q unknown, must be KB/MB/GB/TB", l.LogUnit)
+		}
+	default:
+		errs.add("Logger.LogType: %q unknown, must be daily or roll", l.LogType)
+	}
+}
+
+func (c AllConfig) checkDb(errs *CheckErrors) {
+	d := c.Db
+	if len(d.Driver) == 0 {
+		if len(d.Slaves) > 0 {
+			errs.add("Db.Slaves is set but Db.Driver is empty")
+		}
+		return
+	}
+	checkDbStd(errs, "Db", d.DbStdConfig)
+	for i, slave := range d.Slaves {
+		checkDbStd(errs, fmt.Sprintf("Db.Slaves[%d]", i), slave)
+	}
+	if d.MaxIdleConns < 0 {
+		errs.add("Db.MaxIdleConns: %d must not be negative", d.MaxIdleConns)
+	}
+	if d.MaxOpenConns < 0 {
+		errs.add("Db.MaxOpenConns: %d must not be negative", d.MaxOpenConns)
+	}
+	if d.KeepAlive < 0 {
+		errs.add("Db.KeepAlive: %d must not be negative", d.KeepAlive)
+	}
+	if d.SlowQuery < 0 {
+		errs.add("Db.SlowQuery: %d must not be negative", d.SlowQuery)
+	}
+	if len(d.SlowQueryLog) > 0 && d.SlowQuery == 0 {
+		errs.add("Db.SlowQueryLog is set but Db.SlowQuery is 0")
+	}
+	if len(d.Policy) > 0 && !inList(strings.ToLower(d.Policy), DbPolicies...) {
+		errs.add("Db.Policy: %q unknown, must be one of %v", d.Policy, DbPolicies)
+	}
+	for i, slave := range d.Slaves {
+		if slave.Weight < 0 {
+			errs.add("Db.Slaves[%d].Weight: %d must not be negative", i, slave.Weight)
+		}
+	}
+	if d.ReadYourWrites < 0 {
+		errs.add("Db.ReadYourWrites: %d must not be negative", d.ReadYourWrites)
+	}
+	if d.HealthCheck < 0 {
+		errs.add("Db.HealthCheck: %d must not be negative", d.HealthCheck)
+	}
+	if d.HealthCheckFails < 0 {
+		errs.add("Db.HealthCheckFails: %d must not be negative", d.HealthCheckFails)
+	}
+	if d.CacheTimeout < 0 {
+		errs.add("Db.CacheTimeout: %d must not be negative", d.CacheTimeout)
+	}
+	switch d.CacheType {
+	case "", "memory":
+	case "memcache":
+		if len(c.Cache.Servers) == 0 {
+			errs.add("Db.CacheType is memcache but Cache.Servers is empty")
+		}
+	case "redis":
+		if len(c.Redis.Server) == 0 {
+			errs.add("Db.CacheType is redis but Redis.Server is empty")
+		}
+	default:
+		errs.add("Db.CacheType: %q unknown, must be memory/memcache/redis", d.CacheType)
+	}
+}
+
+func checkDbStd(errs *CheckErrors, name string, d DbStdConfig) {
+	if !inList(strings.ToLower(d.Driver), DbDrivers...) {
+		errs.add("%s.Driver: %q unknown, must be one of %v", name, d.Driver, DbDrivers)
+		return
+	}
+	//sqlite只需要Dbname(文件路径)
+	if len(d.Address) == 0 && !inList(strings.ToLower(d.Driver), "sqlite", "sqlite3") {
+		errs.add("%s.Address is empty", name)
+	}
+	if len(d.Dbname) == 0 {
+		errs.add("%s.Dbname is empty", name)
+	}
+}
+
+func (c AllConfig) checkRedis(errs *CheckErrors) {
+	r := c.Redis
+	if len(r.Server) == 0 {
+		return
+	}
+	if r.IsCluster && r.Db > 0 {
+		errs.add("Redis.Db is not supported in cluster mode")
+	}
+	if r.PoolSize < 0 {
+		errs.add("Redis.PoolSize: %d must not be negative", r.PoolSize)
+	}
+	if r.Expiration < 0 {
+		errs.add("Redis.Expiration: %d must not be negative", r.Expiration)
+	}
+}
+
+func absPath(appPath, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(appPath, path)
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+func inList(s string, list ...string) bool {
+	for _, v := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	var c AllConfig
+	if err := c.Check("/"); err != nil {
+		t.Fatalf("empty config want ok got:%v", err)
+	}
+
+	c.Db.Driver = "oracle"
+	c.Logger.LogFile = "/tmp/test.log"
+	c.Logger.LogType = "hourly"
+	c.Session.CookieName = "sid"
+	c.Session.CacheType = "redis"
+	c.Server.HTTPSCertFile = "not_exist.crt"
+	err := c.Check("/")
+	errs, ok := err.(CheckErrors)
+	if !ok || len(errs) != 5 {
+		t.Fatalf("want 5 problems got:%v", err)
+	}
+}
@@ -13,7 +13,13 @@ type AllConfig struct {
 	Redis     RedisConfig
 	Session   SessionConfig
 	HotDeploy HotDeployConfig
-	Custom    map[string]string
+	Rbac      RbacConfig
+	Reload    ReloadConfig
+	Shutdown  ShutdownConfig
+	Admin     AdminConfig
+	//错误码对应的提示，如"403" = "no permission"
+	ErrorMap map[string]string
+	Custom   map[string]string
 }
 
 type RedisConfig struct {
@@ -44,7 +50,12 @@ type ServerConfig struct {
 	WriteTimeout  time.Duration
 	HTTPSCertFile string
 	HTTPSKeyFile  string
-	HTTPSPhrase   string
+	//HTTPSKeyFile是加密的PEM时的密码，需要开启Upgrade
+	HTTPSPhrase string `secret:"true"`
+	//开启后kill -TERM或者-HUP启动新进程，新进程就绪后旧进程才退出，新进程启动失败旧进程继续服务
+	Upgrade bool
+	//等待新进程就绪的秒数，默认30
+	UpgradeTimeout time.Duration
 }
 
 //LoggerConfig ..
@@ -69,9 +80,25 @@ type DbConfig struct {
 	CacheType    string
 	CacheMaxSize int
 	CacheTimeout time.Duration
+	//不打印普通的sql，慢查询和出错的sql照常打印
+	//没有配置的时候，设置了SlowQuery就默认为true，只打印慢查询，需要打印所有sql的时候配置为false
+	HideSQL *bool
+	//慢查询的阈值，毫秒，0表示不记录慢查询
+	SlowQuery int
+	//慢查询单独写入的文件，相对路径在APPPATH下，空表示写入普通日志
+	SlowQueryLog string
 
 	//从库
 	Slaves []DbStdConfig
+	//从库的负载均衡策略，roundrobin(默认)、random、weightroundrobin、weightrandom、leastconn
+	//weight开头的按Slaves里的Weight
+	Policy string
+	//写之后多少毫秒内，同一个ctx(见db.WithReadYourWrites)的读走主库，0表示不启用
+	ReadYourWrites int
+	//从库健康检查的间隔，秒，0表示不检查
+	HealthCheck time.Duration
+	//连续失败多少次后摘除从库，默认3次，摘除后检查成功一次就加回来
+	HealthCheckFails int
 }
 
 type DbStdConfig struct {
@@ -83,6 +110,8 @@ type DbStdConfig struct {
 	Port     string
 	Dbname   string
 	Params   string
+	//权重，只对从库有效，默认1
+	Weight int
 }
 
 //CacheConfig ..
@@ -118,4 +147,43 @@ type HotDeployConfig struct {
 	Exts []string
 	//指定监听的目录深度，默认最大10
 	Dep int
+	//重启前执行的编译命令，如go build -o app，失败的时候输出错误并保留当前进程
+	BuildCmd string
+	//忽略的文件或目录，glob格式，匹配相对项目路径的路径或者文件名，如vendor、*_test.go、logs/*
+	Ignores []string
+	//最后一次变化后等待多少毫秒再重启，默认1000
+	Debounce int
+}
+
+//RbacConfig 基于角色的权限控制
+type RbacConfig struct {
+	Enable bool
+	//从session里取角色的key，值可以是string(逗号分隔)或[]string，默认roles
+	SessionKey string
+	//角色对应的权限列表，权限可以是路由(user/index、user/*、*)或者controller声明的权限名
+	Roles map[string][]string
+}
+
+//ReloadConfig 配置文件修改后自动重载，也可以kill -USR1 pid手动触发
+//只有Logger、Template、Server.Concurrence、Rbac、ErrorMap、Custom支持重载
+type ReloadConfig struct {
+	Enable bool
+}
+
+//ShutdownConfig 关闭各阶段的超时，单位秒
+type ShutdownConfig struct {
+	//等待处理中的http和grpc请求，默认30
+	HTTPTimeout time.Duration
+	//等待后台任务，默认30
+	WorkerTimeout time.Duration
+	//关闭连接池，默认10
+	CloseTimeout time.Duration
+}
+
+//AdminConfig 管理接口，如/worker/status、/cron/jobs，默认不开启
+type AdminConfig struct {
+	Enable bool
+	//请求头X-Admin-Token或者参数token需要和Token一致
+	//Token为空的时候只能GET查看，不能POST修改
+	Token string `secret:"true"`
 }
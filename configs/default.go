@@ -0,0 +1,152 @@
+package configs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Duration 可以在toml里用字符串配置，如"10s"、"1m30s"
+type Duration struct {
+	time.Duration
+}
+
+//UnmarshalText ..
+func (d *Duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return
+}
+
+//MarshalText ..
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+//SetDefaults 根据struct tag `default:"..."`给零值字段设置默认值
+//v必须是struct的指针，嵌套的struct会递归处理
+//slice用逗号分隔，如`default:"a,b"`
+func SetDefaults(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("SetDefaults need pointer of struct")
+	}
+
+	return setDefaults(rv.Elem())
+}
+
+func setDefaults(rv reflect.Value) (err error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			if !isZero(fv) {
+				continue
+			}
+			if err = SetValue(fv, def); err != nil {
+				return fmt.Errorf("default of %s: %v", field.Name, err)
+			}
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Struct:
+			err = setDefaults(fv)
+		case reflect.Ptr:
+			if !fv.IsNil() && fv.Elem().Kind() == reflect.Struct {
+				err = setDefaults(fv.Elem())
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+//SetValue 把字符串转换为字段的类型并赋值
+//支持encoding.TextUnmarshaler、基本类型、time.Duration和以上类型的slice(逗号分隔)、指针
+func SetValue(rv reflect.Value, s string) (err error) {
+	if rv.CanAddr() {
+		if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	if rv.Type() == reflect.TypeOf(time.Duration(0)) {
+		//纯数字保持原样，由使用方决定单位
+		if n, e := strconv.ParseInt(s, 10, 64); e == nil {
+			rv.SetInt(n)
+			return
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		if len(s) > 0 {
+			items = strings.Split(s, ",")
+		}
+		sl := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err = SetValue(sl.Index(i), strings.TrimSpace(item)); err != nil {
+				return
+			}
+		}
+		rv.Set(sl)
+	case reflect.Ptr:
+		//如*bool，用于区分没有配置和配置为零值
+		v := reflect.New(rv.Type().Elem())
+		if err = SetValue(v.Elem(), s); err != nil {
+			return
+		}
+		rv.Set(v)
+	default:
+		return fmt.Errorf("unsupported type: %s", rv.Type())
+	}
+
+	return
+}
+
+func isZero(rv reflect.Value) bool {
+	return reflect.DeepEqual(rv.Interface(), reflect.Zero(rv.Type()).Interface())
+}
@@ -0,0 +1,32 @@
+package configs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetDefaults(t *testing.T) {
+	var c struct {
+		Name    string   `default:"hfw"`
+		Num     int      `default:"10"`
+		Timeout Duration `default:"1m30s"`
+		Hosts   []string `default:"a, b"`
+		Keep    string   `default:"default"`
+		Sub     struct {
+			Enable bool `default:"true"`
+		}
+	}
+	c.Keep = "keep"
+	if err := SetDefaults(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "hfw" || c.Num != 10 || c.Keep != "keep" || !c.Sub.Enable {
+		t.Fatalf("got: %+v", c)
+	}
+	if c.Timeout.Duration != 90*time.Second {
+		t.Fatalf("want:1m30s got:%s", c.Timeout)
+	}
+	if len(c.Hosts) != 2 || c.Hosts[1] != "b" {
+		t.Fatalf("want:[a b] got:%v", c.Hosts)
+	}
+}
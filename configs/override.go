@@ -0,0 +1,263 @@
+package configs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//EnvPrefix 环境变量覆盖配置的前缀
+const EnvPrefix = "HFW_"
+
+//ApplyEnv 用环境变量覆盖配置，environ格式同os.Environ()
+//变量名为prefix加上字段路径，如HFW_DB_PASSWORD对应Db.Password，HFW_LOGGER_LOG_LEVEL对应Logger.LogLevel
+//匹配时忽略大小写和下划线，嵌入的struct(如DbStdConfig)不计入路径，map不支持
+//返回被覆盖的变量名
+func ApplyEnv(v interface{}, prefix string, environ []string) (keys []string, err error) {
+	rv, err := structElem(v)
+	if err != nil {
+		return
+	}
+
+	fields := make(map[string]reflect.Value)
+	walkFields(rv, nil, func(path []string, fv reflect.Value) {
+		fields[normalizeKey(strings.Join(path, ""))] = fv
+	})
+
+	for _, kv := range environ {
+		tmp := strings.SplitN(kv, "=", 2)
+		if len(tmp) != 2 || !strings.HasPrefix(tmp[0], prefix) {
+			continue
+		}
+		fv, ok := fields[normalizeKey(strings.TrimPrefix(tmp[0], prefix))]
+		if !ok {
+			continue
+		}
+		if err = SetValue(fv, tmp[1]); err != nil {
+			return keys, fmt.Errorf("env %s: %v", tmp[0], err)
+		}
+		keys = append(keys, tmp[0])
+	}
+
+	return
+}
+
+//SetByPath 按路径设置配置，如Db.Password、Server.Concurrence、Custom.key，不区分大小写
+func SetByPath(v interface{}, path string, value string) (err error) {
+	rv, err := structElem(v)
+	if err != nil {
+		return
+	}
+
+	keys := strings.Split(path, ".")
+	for i, key := range keys {
+		if rv.Kind() == reflect.Map {
+			if i != len(keys)-1 || rv.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("config path %s not support", path)
+			}
+			if rv.IsNil() {
+				rv.Set(reflect.MakeMap(rv.Type()))
+			}
+			mv := reflect.New(rv.Type().Elem()).Elem()
+			if err = SetValue(mv, value); err != nil {
+				return
+			}
+			rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), mv)
+			return
+		}
+		if rv.Kind() != reflect.Struct {
+			return fmt.Errorf("config path %s not exist", path)
+		}
+		fv, ok := findField(rv, key)
+		if !ok {
+			return fmt.Errorf("config path %s not exist", path)
+		}
+		rv = fv
+	}
+
+	return SetValue(rv, value)
+}
+
+//Mask 把配置转换为map，密码等敏感字段用******代替，用于打印
+//字段名包含Password、Secret、Phrase，或者tag为`secret:"true"`的字段视为敏感
+//map里key包含password、secret、token、key的值也视为敏感
+//secrets是其他需要隐藏的路径，如DecryptAllPaths返回的解密过的路径
+func Mask(v interface{}, secrets ...string) map[string]interface{} {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	paths := make(map[string]bool, len(secrets))
+	for _, s := range secrets {
+		paths[s] = true
+	}
+
+	return maskStruct(rv, "", paths)
+}
+
+const masked = "******"
+
+//path和DecryptAllPaths的格式一样，嵌入的struct也包含名字
+func maskStruct(rv reflect.Value, path string, secrets map[string]bool) map[string]interface{} {
+	m := make(map[string]interface{})
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanInterface() {
+			continue
+		}
+		p := joinPath(path, field.Name)
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			for k, v := range maskStruct(fv, p, secrets) {
+				m[k] = v
+			}
+			continue
+		}
+		if isSecret(field) || secrets[p] {
+			if !isZero(fv) {
+				m[field.Name] = masked
+			}
+			continue
+		}
+		if val := maskValue(fv, p, secrets); val != nil {
+			m[field.Name] = val
+		}
+	}
+
+	return m
+}
+
+func maskValue(rv reflect.Value, path string, secrets map[string]bool) interface{} {
+	if _, ok := rv.Interface().(encoding.TextMarshaler); ok {
+		return rv.Interface()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return maskStruct(rv, path, secrets)
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return maskValue(rv.Elem(), path, secrets)
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Struct {
+			s := make([]map[string]interface{}, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				s[i] = maskStruct(rv.Index(i), fmt.Sprintf("%s[%d]", path, i), secrets)
+			}
+			return s
+		}
+		if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			for _, k := range rv.MapKeys() {
+				p := joinPath(path, k.String())
+				if secrets[p] || isSecretKey(k.String()) {
+					m[k.String()] = masked
+				} else if val := maskValue(rv.MapIndex(k), p, secrets); val != nil {
+					m[k.String()] = val
+				}
+			}
+			return m
+		}
+	}
+
+	return rv.Interface()
+}
+
+func joinPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+
+	return path + "." + name
+}
+
+func isSecret(field reflect.StructField) bool {
+	if field.Tag.Get("secret") == "true" {
+		return true
+	}
+	for _, s := range []string{"Password", "Secret", "Phrase"} {
+		if strings.Contains(field.Name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//map的key，如Custom里的db_password、api_token
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"password", "secret", "token", "key"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//遍历所有叶子字段，path不包含嵌入struct的名字
+func walkFields(rv reflect.Value, path []string, fn func([]string, reflect.Value)) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		p := path
+		if !field.Anonymous {
+			p = append(append([]string{}, path...), field.Name)
+		}
+		_, isText := fv.Addr().Interface().(encoding.TextUnmarshaler)
+		switch {
+		case isText:
+			fn(p, fv)
+		case fv.Kind() == reflect.Struct:
+			walkFields(fv, p, fn)
+		case fv.Kind() == reflect.Map:
+		default:
+			fn(p, fv)
+		}
+	}
+}
+
+func findField(rv reflect.Value, name string) (fv reflect.Value, ok bool) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !rv.Field(i).CanSet() {
+			continue
+		}
+		if strings.EqualFold(field.Name, name) {
+			return rv.Field(i), true
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if fv, ok = findField(rv.Field(i), name); ok {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func structElem(v interface{}) (rv reflect.Value, err error) {
+	rv = reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return rv, errors.New("need pointer of struct")
+	}
+
+	return rv.Elem(), nil
+}
+
+func normalizeKey(key string) string {
+	return strings.ToUpper(strings.Replace(key, "_", "", -1))
+}
@@ -0,0 +1,41 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	var c AllConfig
+	keys, err := ApplyEnv(&c, EnvPrefix, []string{
+		"HFW_DB_PASSWORD=secret",
+		"HFW_LOGGER_LOG_LEVEL=info",
+		"HFW_SERVER_CONCURRENCE=100",
+		"HFW_UNKNOWN=1",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("want 3 keys got:%v", keys)
+	}
+	if c.Db.Password != "secret" || c.Logger.LogLevel != "info" || c.Server.Concurrence != 100 {
+		t.Fatalf("got: %+v", c)
+	}
+
+	if err = SetByPath(&c, "custom.foo", "bar"); err != nil || c.Custom["foo"] != "bar" {
+		t.Fatalf("SetByPath custom got: %v %v", c.Custom, err)
+	}
+	if err = SetByPath(&c, "db.hidesql", "false"); err != nil || c.Db.HideSQL == nil || *c.Db.HideSQL {
+		t.Fatalf("SetByPath pointer got: %v %v", c.Db.HideSQL, err)
+	}
+
+	c.Custom["api_token"] = "t"
+	m := Mask(c, "Custom.foo")
+	if m["Db"].(map[string]interface{})["Password"] != "******" {
+		t.Fatalf("password not masked: %v", m["Db"])
+	}
+	if custom := m["Custom"].(map[string]interface{}); custom["foo"] != "******" || custom["api_token"] != "******" {
+		t.Fatalf("custom not masked: %v", custom)
+	}
+}
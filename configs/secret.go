@@ -0,0 +1,129 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hsyan2008/hfw2/crypto"
+)
+
+//配置里的加密值格式为ENC(base64密文)，用crypto.AesCrypt加解密
+const (
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+//IsEncrypted 是否是ENC(...)格式
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
+
+//EncryptValue 加密为ENC(...)格式，key长度不能小于16
+func EncryptValue(key, plainText string) (s string, err error) {
+	if len(key) < 16 {
+		return "", errors.New("config key length must be at least 16")
+	}
+	s, err = crypto.NewAesCrypt(key).Encrypt2Base64(plainText)
+	if err != nil {
+		return
+	}
+
+	return encPrefix + s + encSuffix, nil
+}
+
+//DecryptValue 解密ENC(...)格式的值，其他格式原样返回
+func DecryptValue(key, s string) (plainText string, err error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	if len(key) < 16 {
+		return "", errors.New("config key length must be at least 16")
+	}
+	//密文错误的时候AesCrypt可能会panic
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("decrypt failed: %v", e)
+		}
+	}()
+
+	return crypto.NewAesCrypt(key).Baes642Decrypt(s[len(encPrefix) : len(s)-len(encSuffix)])
+}
+
+//DecryptAll 解密v里所有ENC(...)格式的字符串，包括struct、slice和map里的
+//v必须是指针
+func DecryptAll(v interface{}, key string) (err error) {
+	_, err = DecryptAllPaths(v, key)
+	return
+}
+
+//DecryptAllPaths 和DecryptAll一样，并返回解密过的路径，如Db.DbStdConfig.Password、Custom.token
+//用于Mask隐藏解密后的值
+func DecryptAllPaths(v interface{}, key string) (paths []string, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("DecryptAll need pointer")
+	}
+	err = decryptValue(rv.Elem(), key, "", &paths)
+
+	return
+}
+
+func decryptValue(rv reflect.Value, key, path string, paths *[]string) (err error) {
+	switch rv.Kind() {
+	case reflect.String:
+		if !IsEncrypted(rv.String()) {
+			return
+		}
+		s, err := DecryptValue(key, rv.String())
+		if err != nil {
+			return fmt.Errorf("%s: %v", strings.TrimPrefix(path, "."), err)
+		}
+		rv.SetString(s)
+		*paths = append(*paths, strings.TrimPrefix(path, "."))
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			return decryptValue(rv.Elem(), key, path, paths)
+		}
+	case reflect.Interface:
+		//interface里的值不能直接修改，需要复制后重新赋值，如map[string]interface{}
+		if rv.IsNil() || !rv.CanSet() {
+			return
+		}
+		ev := reflect.New(rv.Elem().Type()).Elem()
+		ev.Set(rv.Elem())
+		if err = decryptValue(ev, key, path, paths); err != nil {
+			return
+		}
+		rv.Set(ev)
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if !rv.Field(i).CanSet() {
+				continue
+			}
+			if err = decryptValue(rv.Field(i), key, path+"."+rt.Field(i).Name, paths); err != nil {
+				return
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err = decryptValue(rv.Index(i), key, fmt.Sprintf("%s[%d]", path, i), paths); err != nil {
+				return
+			}
+		}
+	case reflect.Map:
+		//map的值不能直接修改，需要复制后重新赋值
+		for _, k := range rv.MapKeys() {
+			mv := reflect.New(rv.Type().Elem()).Elem()
+			mv.Set(rv.MapIndex(k))
+			if err = decryptValue(mv, key, fmt.Sprintf("%s.%v", path, k), paths); err != nil {
+				return
+			}
+			rv.SetMapIndex(k, mv)
+		}
+	}
+
+	return
+}
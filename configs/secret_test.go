@@ -0,0 +1,68 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestDecryptAll(t *testing.T) {
+	key := "0123456789abcdef"
+	enc, err := EncryptValue(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c AllConfig
+	c.Db.Password = enc
+	c.Db.Slaves = []DbStdConfig{{Password: enc}}
+	c.Custom = map[string]string{"token": enc, "name": "hfw", "dsn": enc}
+	paths, err := DecryptAllPaths(&c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("want 4 paths got: %v", paths)
+	}
+	if m := Mask(c, paths...); m["Custom"].(map[string]interface{})["dsn"] != "******" {
+		t.Fatalf("decrypted value not masked: %v", m["Custom"])
+	}
+	if c.Db.Password != "secret" || c.Db.Slaves[0].Password != "secret" ||
+		c.Custom["token"] != "secret" || c.Custom["name"] != "hfw" || c.Custom["dsn"] != "secret" {
+		t.Fatalf("got: %+v", c)
+	}
+
+	c.Redis.Password = "ENC(bad)"
+	if err = DecryptAll(&c, key); err == nil {
+		t.Fatal("want error for bad ciphertext")
+	}
+}
+
+func TestDecryptAllInterface(t *testing.T) {
+	key := "0123456789abcdef"
+	enc, err := EncryptValue(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		M map[string]interface{}
+		I interface{}
+		L []interface{}
+		P *string
+	}
+	s := enc
+	v.M = map[string]interface{}{"token": enc, "port": 6379, "sub": map[string]interface{}{"key": enc}}
+	v.I = enc
+	v.L = []interface{}{enc, 1}
+	v.P = &s
+	paths, err := DecryptAllPaths(&v, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 5 {
+		t.Fatalf("want 5 paths got: %v", paths)
+	}
+	if v.M["token"] != "secret" || v.M["port"] != 6379 || v.M["sub"].(map[string]interface{})["key"] != "secret" ||
+		v.I != "secret" || v.L[0] != "secret" || v.L[1] != 1 || *v.P != "secret" {
+		t.Fatalf("got: %+v", v)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw2/common"
@@ -152,3 +153,28 @@ func (httpCtx *HTTPContext) SetCookie(key, value string) {
 	}
 	http.SetCookie(httpCtx.ResponseWriter, cookie)
 }
+
+var contextHooks = struct {
+	l    *sync.RWMutex
+	list []func(ctx context.Context) context.Context
+}{
+	l: &sync.RWMutex{},
+}
+
+//RegisterContextHook 每个请求生成httpCtx.Ctx后按注册顺序调用，用于往Ctx里加值
+//如db包的read-your-writes，见db/replica.go
+func RegisterContextHook(f func(ctx context.Context) context.Context) {
+	contextHooks.l.Lock()
+	defer contextHooks.l.Unlock()
+	contextHooks.list = append(contextHooks.list, f)
+}
+
+func applyContextHooks(ctx context.Context) context.Context {
+	contextHooks.l.RLock()
+	defer contextHooks.l.RUnlock()
+	for _, f := range contextHooks.list {
+		ctx = f(ctx)
+	}
+
+	return ctx
+}
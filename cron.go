@@ -1,6 +1,16 @@
 package hfw
 
-import "github.com/robfig/cron"
+import (
+	"errors"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/redis"
+	"github.com/robfig/cron"
+)
+
+//DefaultCronLockTTL 分布式锁默认的过期时间
+const DefaultCronLockTTL = 60 * time.Second
 
 var crontab *cron.Cron
 
@@ -13,6 +23,95 @@ func AddCron(spec string, cmd func()) error {
 	return crontab.AddFunc(spec, cmd)
 }
 
+//CronLockOptions 多实例部署的时候，用redis锁保证每次只有一个实例执行
+type CronLockOptions struct {
+	//锁的key，各实例必须一致
+	Key string
+	//锁的过期时间，执行期间每TTL/3续期一次，默认DefaultCronLockTTL
+	TTL time.Duration
+	//默认redis.DefaultRedisIns
+	Redis redis.RedisInterface
+}
+
+//AddLockedCron 每次执行前抢redis锁，抢到的实例才执行，token是锁的fencing token，可以传给下游拒绝过期的写入
+//执行完后锁至少持有到离下次执行的一半时间，避免时钟有偏差的实例重复执行，panic的时候立即释放
+func AddLockedCron(spec string, cmd func(token int64), opt CronLockOptions) error {
+	if len(opt.Key) == 0 {
+		return errors.New("cron lock key is empty")
+	}
+	if opt.TTL < time.Second {
+		opt.TTL = DefaultCronLockTTL
+	}
+	sched, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	crontab.Schedule(sched, cron.FuncJob(func() {
+		runLockedCron(sched, cmd, opt)
+	}))
+
+	return nil
+}
+
+func runLockedCron(sched cron.Schedule, cmd func(token int64), opt CronLockOptions) {
+	ins := opt.Redis
+	if ins == nil {
+		ins = redis.DefaultRedisIns
+	}
+	lock := redis.NewLock(ins, opt.Key, int(opt.TTL/time.Second))
+	ok, err := lock.TryLock()
+	if err != nil {
+		logger.Warnf("cron %s lock failed: %v", opt.Key, err)
+		return
+	}
+	if !ok {
+		logger.Debugf("cron %s is locked by other instance", opt.Key)
+		return
+	}
+
+	startAt := time.Now()
+	stop := make(chan struct{})
+	go renewCronLock(lock, opt, stop)
+	defer func() {
+		close(stop)
+		if e := recover(); e != nil {
+			if err := lock.Unlock(); err != nil {
+				logger.Warnf("cron %s unlock failed: %v", opt.Key, err)
+			}
+			panic(e)
+		}
+		hold := sched.Next(startAt).Sub(startAt)/2 - time.Since(startAt)
+		if err := lock.ExpireAfter(hold); err != nil {
+			logger.Warnf("cron %s release lock failed: %v", opt.Key, err)
+		}
+	}()
+
+	cmd(lock.Token())
+}
+
+func renewCronLock(lock *redis.Lock, opt CronLockOptions, stop chan struct{}) {
+	ticker := time.NewTicker(opt.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := lock.Refresh(); err != nil {
+				logger.Warnf("cron %s renew lock failed: %v", opt.Key, err)
+				if err == redis.ErrLockNotHeld {
+					return
+				}
+			}
+		}
+	}
+}
+
+//StopCron 停止调度，并等待AddCronJob添加的执行中的任务
 func StopCron() {
 	crontab.Stop()
+	cronJobs.l.Lock()
+	cronJobs.stopped = true
+	cronJobs.l.Unlock()
+	cronJobs.wg.Wait()
 }
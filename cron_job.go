@@ -0,0 +1,342 @@
+package hfw
+
+//有名字的定时任务，记录执行历史，可以手动触发
+//用法
+//err := hfw.AddCronJob(hfw.CronJob{
+//	Name:     "report",
+//	Spec:     "0 30 9 * * *",
+//	Location: "Asia/Shanghai",
+//	Run:      func(ctx context.Context) error { ... },
+//})
+//Spec支持秒，6段：秒 分 时 日 月 周，也支持@every 1m30s、@daily等，也可以用TZ=Asia/Shanghai开头指定时区
+//上一次还没结束的时候跳过本次，关闭的时候ctx被取消，并等待执行中的任务
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/encoding"
+	"github.com/robfig/cron"
+)
+
+//DefaultCronHistorySize 每个任务保留的执行记录数
+const DefaultCronHistorySize = 10
+
+//ErrCronJobRunning 任务正在执行
+var ErrCronJobRunning = errors.New("cron job is running")
+
+//ErrCronStopped 已经关闭，不再执行
+var ErrCronStopped = errors.New("cron is stopped")
+
+//CronJob 定时任务
+type CronJob struct {
+	Name string
+	Spec string
+	//时区，如Asia/Shanghai，默认本地时区
+	Location string
+	//执行超时，为0不限制
+	Timeout time.Duration
+	//多实例部署的时候用redis锁保证只有一个实例执行，token用CronLockToken获取
+	Lock *CronLockOptions
+	Run  func(ctx context.Context) error
+}
+
+//CronRun 一次执行的记录
+type CronRun struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+	//手动触发的
+	Manual bool `json:"manual,omitempty"`
+}
+
+//CronJobStatus 任务的状态
+type CronJobStatus struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec"`
+	Running bool      `json:"running"`
+	Next    time.Time `json:"next"`
+	//执行次数和因为上一次未结束跳过的次数
+	Runs    int64     `json:"runs"`
+	Skipped int64     `json:"skipped"`
+	History []CronRun `json:"history"`
+}
+
+type cronJob struct {
+	CronJob
+	sched cron.Schedule
+
+	mu      *sync.Mutex
+	running bool
+	runs    int64
+	skipped int64
+	//最新的在前面
+	history []CronRun
+}
+
+var cronJobs = struct {
+	l    *sync.RWMutex
+	list map[string]*cronJob
+	//执行中的任务，StopCron的时候等待
+	wg *sync.WaitGroup
+	//StopCron之后不再启动新的执行，和wg.Add一起用l保护
+	stopped bool
+}{
+	l:    new(sync.RWMutex),
+	list: make(map[string]*cronJob),
+	wg:   new(sync.WaitGroup),
+}
+
+type cronLockTokenKey struct{}
+
+//CronLockToken 加了redis锁的任务的fencing token，没有加锁的返回0
+func CronLockToken(ctx context.Context) int64 {
+	token, _ := ctx.Value(cronLockTokenKey{}).(int64)
+	return token
+}
+
+//AddCronJob 添加任务，名字不能重复
+func AddCronJob(job CronJob) (err error) {
+	if len(job.Name) == 0 {
+		return errors.New("cron job name is empty")
+	}
+	if job.Run == nil {
+		return fmt.Errorf("cron job %s run is nil", job.Name)
+	}
+	if job.Lock != nil && len(job.Lock.Key) == 0 {
+		lock := *job.Lock
+		lock.Key = "cron:" + job.Name
+		job.Lock = &lock
+	}
+	sched, err := parseCronSpec(job.Spec, job.Location)
+	if err != nil {
+		return fmt.Errorf("cron job %s: %v", job.Name, err)
+	}
+
+	j := &cronJob{CronJob: job, sched: sched, mu: new(sync.Mutex)}
+	cronJobs.l.Lock()
+	defer cronJobs.l.Unlock()
+	if _, ok := cronJobs.list[job.Name]; ok {
+		return fmt.Errorf("cron job %s has registered", job.Name)
+	}
+	cronJobs.list[job.Name] = j
+	crontab.Schedule(sched, cron.FuncJob(func() {
+		_ = j.run(false)
+	}))
+
+	return
+}
+
+//GetCronJobs 所有任务的状态，按名字排序
+func GetCronJobs() (list []CronJobStatus) {
+	cronJobs.l.RLock()
+	defer cronJobs.l.RUnlock()
+	now := time.Now()
+	for _, j := range cronJobs.list {
+		j.mu.Lock()
+		list = append(list, CronJobStatus{
+			Name:    j.Name,
+			Spec:    j.Spec,
+			Running: j.running,
+			Next:    j.sched.Next(now),
+			Runs:    j.runs,
+			Skipped: j.skipped,
+			History: append([]CronRun(nil), j.history...),
+		})
+		j.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return
+}
+
+//TriggerCronJob 立即异步执行一次，正在执行的返回ErrCronJobRunning
+func TriggerCronJob(name string) error {
+	cronJobs.l.RLock()
+	j, ok := cronJobs.list[name]
+	cronJobs.l.RUnlock()
+	if !ok {
+		return fmt.Errorf("cron job %s not found", name)
+	}
+	if err := j.tryStart(); err != nil {
+		return err
+	}
+	go j.exec(true)
+
+	return nil
+}
+
+func (j *cronJob) run(manual bool) error {
+	err := j.tryStart()
+	if err == ErrCronStopped {
+		return nil
+	}
+	if err != nil {
+		j.mu.Lock()
+		j.skipped++
+		j.mu.Unlock()
+		logger.Warnf("cron job %s skipped, last run has not finished", j.Name)
+		return err
+	}
+	j.exec(manual)
+
+	return nil
+}
+
+//关闭的时候不再启动新的执行
+//持有cronJobs.l的读锁调用wg.Add，StopCron拿到写锁设置stopped后才Wait
+func (j *cronJob) tryStart() error {
+	if signalContext.Ctx.Err() != nil {
+		return ErrCronStopped
+	}
+	cronJobs.l.RLock()
+	defer cronJobs.l.RUnlock()
+	if cronJobs.stopped {
+		return ErrCronStopped
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running {
+		return ErrCronJobRunning
+	}
+	j.running = true
+	cronJobs.wg.Add(1)
+
+	return nil
+}
+
+func (j *cronJob) exec(manual bool) {
+	defer cronJobs.wg.Done()
+	defer signalContext.AddWork("cron " + j.Name)()
+
+	ctx, cancel := context.WithCancel(signalContext.Ctx)
+	if j.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(signalContext.Ctx, j.Timeout)
+	}
+	defer cancel()
+
+	r := CronRun{StartedAt: time.Now(), Manual: manual}
+	var err error
+	if j.Lock != nil {
+		//panic的时候runLockedCron会立即释放锁
+		var locked bool
+		err = j.call(func() (err error) {
+			runLockedCron(j.sched, func(token int64) {
+				locked = true
+				err = j.Run(context.WithValue(ctx, cronLockTokenKey{}, token))
+			}, *j.Lock)
+			return
+		})
+		if !locked && err == nil {
+			j.mu.Lock()
+			j.running = false
+			j.mu.Unlock()
+			return
+		}
+	} else {
+		err = j.call(func() error {
+			return j.Run(ctx)
+		})
+	}
+	r.Duration = time.Since(r.StartedAt)
+	if err != nil {
+		r.Error = err.Error()
+		logger.Errorf("cron job %s failed after %s: %v", j.Name, r.Duration, err)
+	} else {
+		logger.Infof("cron job %s done in %s", j.Name, r.Duration)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running = false
+	j.runs++
+	j.history = append([]CronRun{r}, j.history...)
+	if len(j.history) > DefaultCronHistorySize {
+		j.history = j.history[:DefaultCronHistorySize]
+	}
+}
+
+//panic转为错误
+func (j *cronJob) call(f func() error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			logger.Errorf("cron job %s panic: %v\n%s", j.Name, e, debug.Stack())
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+
+	return f()
+}
+
+//支持TZ=或者CRON_TZ=开头指定时区，location优先
+func parseCronSpec(spec, location string) (sched cron.Schedule, err error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		i := strings.Index(spec, " ")
+		if i < 0 {
+			return nil, fmt.Errorf("spec %q invalid", spec)
+		}
+		if len(location) == 0 {
+			location = spec[strings.Index(spec, "=")+1 : i]
+		}
+		spec = strings.TrimSpace(spec[i:])
+	}
+	sched, err = cron.Parse(spec)
+	if err != nil || len(location) == 0 {
+		return
+	}
+	loc, err := time.LoadLocation(location)
+	if err != nil {
+		return
+	}
+
+	return locationSchedule{sched: sched, loc: loc}, nil
+}
+
+//按指定时区计算下次执行时间
+type locationSchedule struct {
+	sched cron.Schedule
+	loc   *time.Location
+}
+
+func (s locationSchedule) Next(t time.Time) time.Time {
+	return s.sched.Next(t.In(s.loc))
+}
+
+//GET列出任务，POST带name参数手动触发，见admin.go
+func cronAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		name := r.FormValue("name")
+		if err := TriggerCronJob(name); err != nil {
+			code := http.StatusNotFound
+			switch err {
+			case ErrCronJobRunning:
+				code = http.StatusConflict
+			case ErrCronStopped:
+				code = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		logger.Info("trigger cron job", name)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b, err := encoding.JSON.Marshal(GetCronJobs())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,191 @@
+package hfw
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getTestCronJob(t *testing.T, job CronJob) *cronJob {
+	if job.Spec == "" {
+		job.Spec = "@every 1h"
+	}
+	if err := AddCronJob(job); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cronJobs.l.Lock()
+		delete(cronJobs.list, job.Name)
+		cronJobs.l.Unlock()
+	})
+	cronJobs.l.RLock()
+	defer cronJobs.l.RUnlock()
+	return cronJobs.list[job.Name]
+}
+
+func getTestCronStatus(name string) (status CronJobStatus) {
+	for _, s := range GetCronJobs() {
+		if s.Name == name {
+			return s
+		}
+	}
+	return
+}
+
+func TestCronJobSkipOverlap(t *testing.T) {
+	start, finish := make(chan struct{}), make(chan struct{})
+	j := getTestCronJob(t, CronJob{
+		Name: "test_overlap",
+		Run: func(ctx context.Context) error {
+			close(start)
+			<-finish
+			return nil
+		},
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- j.run(false)
+	}()
+	<-start
+	if err := j.run(false); err != ErrCronJobRunning {
+		t.Fatalf("want ErrCronJobRunning, got %v", err)
+	}
+	if err := TriggerCronJob("test_overlap"); err != ErrCronJobRunning {
+		t.Fatalf("manual trigger while running want ErrCronJobRunning, got %v", err)
+	}
+	if s := getTestCronStatus("test_overlap"); !s.Running || s.Skipped != 1 {
+		t.Fatalf("want running and 1 skipped, got %+v", s)
+	}
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := getTestCronStatus("test_overlap"); s.Running || s.Runs != 1 || len(s.History) != 1 {
+		t.Fatalf("want 1 run, got %+v", s)
+	}
+}
+
+func TestCronJobHistory(t *testing.T) {
+	n := 0
+	j := getTestCronJob(t, CronJob{
+		Name: "test_history",
+		Run: func(ctx context.Context) error {
+			n++
+			switch n {
+			case 1:
+				panic("boom")
+			case 2:
+				return errors.New("failed")
+			}
+			return nil
+		},
+	})
+	for i := 0; i < DefaultCronHistorySize+2; i++ {
+		_ = j.run(false)
+	}
+
+	s := getTestCronStatus("test_history")
+	if s.Runs != int64(DefaultCronHistorySize+2) || len(s.History) != DefaultCronHistorySize {
+		t.Fatalf("want %d runs and %d history, got %d %d", DefaultCronHistorySize+2, DefaultCronHistorySize, s.Runs, len(s.History))
+	}
+	//最新的在前面，最早的两次已经被丢弃
+	for _, r := range s.History {
+		if r.Error != "" || r.Manual {
+			t.Fatalf("history should only keep latest runs, got %+v", s.History)
+		}
+	}
+	if !s.History[0].StartedAt.After(s.History[len(s.History)-1].StartedAt) {
+		t.Fatal("latest run should be first")
+	}
+
+	_ = getTestCronJob(t, CronJob{
+		Name: "test_history_error",
+		Run: func(ctx context.Context) error {
+			panic("boom")
+		},
+	}).run(false)
+	if h := getTestCronStatus("test_history_error").History; len(h) != 1 || h[0].Error != "panic: boom" {
+		t.Fatalf("panic should be recorded as error, got %+v", h)
+	}
+}
+
+func TestTriggerCronJob(t *testing.T) {
+	ran := make(chan struct{})
+	_ = getTestCronJob(t, CronJob{
+		Name: "test_trigger",
+		Run: func(ctx context.Context) error {
+			close(ran)
+			return nil
+		},
+	})
+	if err := TriggerCronJob("test_not_exist"); err == nil {
+		t.Fatal("want not found error")
+	}
+	if err := TriggerCronJob("test_trigger"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("triggered job not run")
+	}
+	for i := 0; i < 100; i++ {
+		if s := getTestCronStatus("test_trigger"); s.Runs == 1 {
+			if len(s.History) != 1 || !s.History[0].Manual {
+				t.Fatalf("want manual run in history, got %+v", s.History)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("triggered job not recorded")
+}
+
+//StopCron和手动触发并发的时候，StopCron要等待已经启动的，之后的不再启动
+func TestStopCronRefuseStart(t *testing.T) {
+	defer func() {
+		cronJobs.l.Lock()
+		cronJobs.stopped = false
+		cronJobs.l.Unlock()
+	}()
+	var mu sync.Mutex
+	running := 0
+	j := getTestCronJob(t, CronJob{
+		Name: "test_stop",
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = TriggerCronJob("test_stop")
+		}()
+	}
+	StopCron()
+	mu.Lock()
+	if running != 0 {
+		t.Fatalf("StopCron returned with %d running", running)
+	}
+	mu.Unlock()
+	wg.Wait()
+	if err := TriggerCronJob("test_stop"); err != ErrCronStopped {
+		t.Fatalf("want ErrCronStopped, got %v", err)
+	}
+	if err := j.run(false); err != nil || getTestCronStatus("test_stop").Running {
+		t.Fatal("scheduled run after StopCron should be ignored")
+	}
+}
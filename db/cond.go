@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//Cond的key是列名，后面可以跟操作符，多个条件用AND连接，如
+//db.Cond{
+//	"status":             1,
+//	"age >=":             18,
+//	"name like":          "%abc%",
+//	"id not in":          []int{1, 2},
+//	"created_at between": []interface{}{start, end},
+//	"deleted_at is null": nil,
+//	"or":                 []db.Cond{{"type": 1}, {"type": 2, "level >": 3}},
+//}
+//or的值是多个Cond，每个Cond内部用AND，之间用OR，可以嵌套，多个or组用or#1、or#2区分
+//列名只能是字母、数字、下划线，可以带表名，如user.id
+var columnRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+//CondOperators 支持的操作符，空表示=
+var CondOperators = []string{"=", "!=", "<>", ">", ">=", "<", "<=", "like", "not like",
+	"in", "not in", "between", "not between", "is null", "is not null"}
+
+//是否是or组的key
+func isOrKey(k string) bool {
+	return k == "or" || strings.HasPrefix(k, "or#")
+}
+
+//buildWhere 把Cond转换为where语句和参数，key按字典序处理，保证生成的sql稳定
+//quote用于给列名加引号
+func buildWhere(quote func(string) string, cond Cond) (where string, args []interface{}, err error) {
+	keys := make([]string, 0, len(cond))
+	for k := range cond {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var str []string
+	for _, key := range keys {
+		v := cond[key]
+		k := strings.ToLower(strings.TrimSpace(key))
+		if isOrKey(k) {
+			s, a, err := buildOr(quote, key, v)
+			if err != nil {
+				return "", nil, err
+			}
+			if len(s) > 0 {
+				str = append(str, s)
+				args = append(args, a...)
+			}
+			continue
+		}
+
+		fields := strings.Fields(k)
+		if len(fields) == 0 {
+			return "", nil, errors.New("cond key is empty")
+		}
+		column := fields[0]
+		if !columnRegexp.MatchString(column) {
+			return "", nil, fmt.Errorf("cond key %q: invalid column name %q", key, column)
+		}
+		column = quoteColumn(quote, column)
+		op := strings.Join(fields[1:], " ")
+		switch op {
+		case "", "=", "!=", "<>", ">", ">=", "<", "<=", "like", "not like":
+			if op == "" {
+				op = "="
+			}
+			str = append(str, fmt.Sprintf("%s %s ?", column, strings.ToUpper(op)))
+			args = append(args, v)
+		case "in", "not in":
+			values := toSlice(v)
+			if len(values) == 0 {
+				//空的in永远不成立，空的not in永远成立
+				if op == "in" {
+					str = append(str, "1 = 0")
+				}
+				continue
+			}
+			str = append(str, fmt.Sprintf("%s %s (%s)", column, strings.ToUpper(op),
+				strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")))
+			args = append(args, values...)
+		case "between", "not between":
+			values := toSlice(v)
+			if len(values) != 2 {
+				return "", nil, fmt.Errorf("cond key %q: value must have 2 elements, got %d", key, len(values))
+			}
+			str = append(str, fmt.Sprintf("%s %s ? AND ?", column, strings.ToUpper(op)))
+			args = append(args, values...)
+		case "is null", "is not null":
+			str = append(str, fmt.Sprintf("%s %s", column, strings.ToUpper(op)))
+		default:
+			return "", nil, fmt.Errorf("cond key %q: unknown operator %q, support %s",
+				key, op, strings.Join(CondOperators, ", "))
+		}
+	}
+
+	return strings.Join(str, " AND "), args, nil
+}
+
+func buildOr(quote func(string) string, key string, v interface{}) (where string, args []interface{}, err error) {
+	var conds []Cond
+	switch vv := v.(type) {
+	case []Cond:
+		conds = vv
+	case []map[string]interface{}:
+		for _, c := range vv {
+			conds = append(conds, Cond(c))
+		}
+	default:
+		return "", nil, fmt.Errorf("cond key %q: value must be []db.Cond, got %T", key, v)
+	}
+
+	var str []string
+	for _, c := range conds {
+		for k := range c {
+			if isSpecialKey(strings.ToLower(k)) {
+				return "", nil, fmt.Errorf("cond key %q: %q not allowed in or group", key, k)
+			}
+		}
+		s, a, err := buildWhere(quote, c)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(s) == 0 {
+			//有一个组为空表示不限制
+			return "", nil, nil
+		}
+		str = append(str, "("+s+")")
+		args = append(args, a...)
+	}
+	if len(str) == 0 {
+		return "1 = 0", nil, nil
+	}
+
+	return "(" + strings.Join(str, " OR ") + ")", args, nil
+}
+
+//buildCond处理的特殊key
+func isSpecialKey(k string) bool {
+	switch k {
+	case "orderby", "page", "pagesize", "where", "sql", "select", "distinct":
+		return true
+	}
+
+	return false
+}
+
+func quoteColumn(quote func(string) string, column string) string {
+	parts := strings.Split(column, ".")
+	for i, p := range parts {
+		parts[i] = quote(p)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+//slice或者array展开，其他的作为一个元素
+func toSlice(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		//[]byte作为一个值
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return []interface{}{v}
+		}
+		values := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values[i] = rv.Index(i).Interface()
+		}
+		return values
+	}
+
+	return []interface{}{v}
+}
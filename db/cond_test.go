@@ -0,0 +1,60 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testQuote(s string) string {
+	return "`" + s + "`"
+}
+
+func TestBuildWhere(t *testing.T) {
+	where, args, err := buildWhere(testQuote, Cond{
+		"status":             1,
+		"Age >=":             18,
+		"name like":          "%a%",
+		"id not in":          []int{1, 2},
+		"created_at between": []interface{}{"2019-01-01", "2019-02-01"},
+		"deleted_at is null": nil,
+		"or": []Cond{
+			{"type": 1},
+			{"type": 2, "u.level >": 3},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "`age` >= ? AND `created_at` BETWEEN ? AND ? AND `deleted_at` IS NULL AND `id` NOT IN (?, ?) AND " +
+		"`name` LIKE ? AND ((`type` = ?) OR (`type` = ? AND `u`.`level` > ?)) AND `status` = ?"
+	if where != expect {
+		t.Fatalf("where:\n%s\nexpect:\n%s", where, expect)
+	}
+	expectArgs := []interface{}{18, "2019-01-01", "2019-02-01", 1, 2, "%a%", 1, 2, 3, 1}
+	if !reflect.DeepEqual(args, expectArgs) {
+		t.Fatalf("args: %v, expect: %v", args, expectArgs)
+	}
+
+	where, _, _ = buildWhere(testQuote, Cond{"id in": []int{}, "uid not in": []int{}})
+	if where != "1 = 0" {
+		t.Fatalf("empty in: %s", where)
+	}
+}
+
+func TestBuildWhereError(t *testing.T) {
+	for _, cond := range []Cond{
+		{"id; drop table user": 1},
+		{"id =1 or 1": 1},
+		{"id regexp": "a"},
+		{"id between": 1},
+		{"or": Cond{"id": 1}},
+		{"or": []Cond{{"page": 1}}},
+	} {
+		if _, _, err := buildWhere(testQuote, cond); err == nil {
+			t.Errorf("%v should fail", cond)
+		} else if !strings.Contains(err.Error(), "cond key") {
+			t.Errorf("error not descriptive: %v", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/go-xorm/xorm"
+)
+
+//WithContext 返回使用ctx执行sql的dao，ctx取消或者超时的时候中断正在执行的sql，如
+//dao.WithContext(httpCtx.Ctx).Search(&users, cond)
+//和原dao共用session，事务中的session也会改为使用ctx
+func (d *XormDao) WithContext(ctx context.Context) *XormDao {
+	dao := *d
+	dao.ctx = ctx
+	if dao.sess != nil && ctx != nil {
+		dao.sess.Context(ctx)
+	}
+
+	return &dao
+}
+
+func (d *XormDao) newSession() *xorm.Session {
+	var sess *xorm.Session
+	if eg, ok := d.engine.(*xorm.EngineGroup); ok && d.readMaster(eg) {
+		sess = eg.Master().NewSession()
+	} else {
+		sess = d.engine.NewSession()
+	}
+	if d.ctx != nil {
+		sess.Context(d.ctx)
+	}
+
+	return sess
+}
+
+//以下是带ctx的版本
+
+func (d *XormDao) UpdateByIdContext(ctx context.Context, t interface{}) (int64, error) {
+	return d.WithContext(ctx).UpdateById(t)
+}
+
+func (d *XormDao) UpdateChangedContext(ctx context.Context, t, old interface{}) (int64, error) {
+	return d.WithContext(ctx).UpdateChanged(t, old)
+}
+
+func (d *XormDao) UpdateByIdsContext(ctx context.Context, t interface{}, params Cond, ids []interface{}) (int64, error) {
+	return d.WithContext(ctx).UpdateByIds(t, params, ids)
+}
+
+func (d *XormDao) UpdateByWhereContext(ctx context.Context, t interface{}, params Cond, where Cond) (int64, error) {
+	return d.WithContext(ctx).UpdateByWhere(t, params, where)
+}
+
+func (d *XormDao) InsertContext(ctx context.Context, t interface{}) (int64, error) {
+	return d.WithContext(ctx).Insert(t)
+}
+
+func (d *XormDao) InsertMultiContext(ctx context.Context, t interface{}) (int64, error) {
+	return d.WithContext(ctx).InsertMulti(t)
+}
+
+func (d *XormDao) SearchOneContext(ctx context.Context, t interface{}, cond Cond) (bool, error) {
+	return d.WithContext(ctx).SearchOne(t, cond)
+}
+
+func (d *XormDao) SearchContext(ctx context.Context, t interface{}, cond Cond) error {
+	return d.WithContext(ctx).Search(t, cond)
+}
+
+func (d *XormDao) RowsContext(ctx context.Context, t interface{}, cond Cond) (*xorm.Rows, error) {
+	return d.WithContext(ctx).Rows(t, cond)
+}
+
+func (d *XormDao) IterateContext(ctx context.Context, t interface{}, cond Cond, f xorm.IterFunc) error {
+	return d.WithContext(ctx).Iterate(t, cond, f)
+}
+
+func (d *XormDao) GetMultiContext(ctx context.Context, t interface{}, ids ...interface{}) error {
+	return d.WithContext(ctx).GetMulti(t, ids...)
+}
+
+func (d *XormDao) CountContext(ctx context.Context, t interface{}, cond Cond) (int64, error) {
+	return d.WithContext(ctx).Count(t, cond)
+}
+
+func (d *XormDao) PaginateContext(ctx context.Context, t interface{}, cond Cond) (Pagination, error) {
+	return d.WithContext(ctx).Paginate(t, cond)
+}
+
+func (d *XormDao) SearchByCursorContext(ctx context.Context, t interface{}, cond Cond, opt CursorOptions) (interface{}, error) {
+	return d.WithContext(ctx).SearchByCursor(t, cond, opt)
+}
+
+func (d *XormDao) SoftDeleteContext(ctx context.Context, t interface{}, ids ...interface{}) (int64, error) {
+	return d.WithContext(ctx).SoftDelete(t, ids...)
+}
+
+func (d *XormDao) RestoreContext(ctx context.Context, t interface{}, ids ...interface{}) (int64, error) {
+	return d.WithContext(ctx).Restore(t, ids...)
+}
+
+func (d *XormDao) HardDeleteContext(ctx context.Context, t interface{}, ids ...interface{}) (int64, error) {
+	return d.WithContext(ctx).HardDelete(t, ids...)
+}
+
+func (d *XormDao) ReplaceContext(ctx context.Context, sqlStr string, cond Cond) (int64, error) {
+	return d.WithContext(ctx).Replace(sqlStr, cond)
+}
+
+func (d *XormDao) ExecContext(ctx context.Context, sqlStr string, args ...interface{}) (sql.Result, error) {
+	return d.WithContext(ctx).Exec(sqlStr, args...)
+}
+
+func (d *XormDao) QueryContext(ctx context.Context, args ...interface{}) ([]map[string][]byte, error) {
+	return d.WithContext(ctx).Query(args...)
+}
+
+func (d *XormDao) QueryStringContext(ctx context.Context, args ...interface{}) ([]map[string]string, error) {
+	return d.WithContext(ctx).QueryString(args...)
+}
+
+func (d *XormDao) QueryInterfaceContext(ctx context.Context, args ...interface{}) ([]map[string]interface{}, error) {
+	return d.WithContext(ctx).QueryInterface(args...)
+}
+
+//BeginContext 用ctx开始事务，ctx取消的时候事务自动回滚
+func (d *XormDao) BeginContext(ctx context.Context) error {
+	if d.sess == nil {
+		return errors.New("please NewSession at first")
+	}
+	d.sess.Context(ctx)
+
+	return d.Begin()
+}
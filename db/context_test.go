@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithContext(t *testing.T) {
+	d := newSqliteDao(t, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var users []testUser
+	if err := d.SearchContext(ctx, &users, Cond{}); err != nil || len(users) != 3 {
+		t.Fatalf("SearchContext want 3, got %d %v", len(users), err)
+	}
+
+	cancel()
+	for name, f := range map[string]func() error{
+		"Search": func() error {
+			var users []testUser
+			return d.WithContext(ctx).Search(&users, Cond{})
+		},
+		"SearchOneContext": func() error {
+			_, err := d.SearchOneContext(ctx, new(testUser), Cond{"id": 1})
+			return err
+		},
+		"CountContext": func() error {
+			_, err := d.CountContext(ctx, new(testUser), Cond{})
+			return err
+		},
+		"PaginateContext": func() error {
+			var users []testUser
+			_, err := d.PaginateContext(ctx, &users, Cond{})
+			return err
+		},
+		"InsertContext": func() error {
+			_, err := d.InsertContext(ctx, &testUser{Name: "x"})
+			return err
+		},
+		"UpdateByIdContext": func() error {
+			_, err := d.UpdateByIdContext(ctx, &testUser{Models: Models{Id: 1}, Name: "x"})
+			return err
+		},
+		"SoftDeleteContext": func() error {
+			_, err := d.SoftDeleteContext(ctx, new(testUser), 1)
+			return err
+		},
+		"ExecContext": func() error {
+			_, err := d.ExecContext(ctx, "UPDATE test_user SET name = 'x'")
+			return err
+		},
+		"QueryStringContext": func() error {
+			_, err := d.QueryStringContext(ctx, "SELECT * FROM test_user")
+			return err
+		},
+	} {
+		if err := f(); err == nil {
+			t.Errorf("%s with canceled ctx should fail", name)
+		}
+	}
+
+	//原dao不受影响
+	var got []testUser
+	if err := d.Search(&got, Cond{"name": "user"}); err != nil || len(got) != 3 {
+		t.Fatalf("dao without ctx want 3 unchanged rows, got %d %v", len(got), err)
+	}
+}
+
+func TestBeginContext(t *testing.T) {
+	d := newSqliteDao(t, 0)
+	if err := d.BeginContext(context.Background()); err == nil {
+		t.Fatal("BeginContext without NewSession should fail")
+	}
+
+	d.NewSession()
+	defer d.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.BeginContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Insert(&testUser{Name: "tx"}); err != nil {
+		t.Fatal(err)
+	}
+	//ctx取消后事务回滚
+	cancel()
+	if err := d.Commit(); err == nil {
+		t.Fatal("Commit after ctx canceled should fail")
+	}
+	d.Close()
+	if n, _ := d.Count(new(testUser), Cond{}); n != 0 {
+		t.Fatalf("tx should be rolled back, got %d rows", n)
+	}
+}
@@ -4,6 +4,7 @@ const DefaultPageSize = 1000
 
 type Dao interface {
 	UpdateById(interface{}) (int64, error)
+	UpdateChanged(interface{}, interface{}) (int64, error)
 	UpdateByIds(interface{}, Cond, []interface{}) (int64, error)
 	UpdateByWhere(interface{}, Cond, Cond) (int64, error)
 	Insert(interface{}) (int64, error)
@@ -11,6 +12,12 @@ type Dao interface {
 	Search(interface{}, Cond) error
 	GetMulti(interface{}, ...interface{}) error
 	Count(interface{}, Cond) (int64, error)
+	Paginate(interface{}, Cond) (Pagination, error)
+	SearchByCursor(interface{}, Cond, CursorOptions) (interface{}, error)
+
+	SoftDelete(interface{}, ...interface{}) (int64, error)
+	Restore(interface{}, ...interface{}) (int64, error)
+	HardDelete(interface{}, ...interface{}) (int64, error)
 
 	EnableCache(interface{})
 	DisableCache(interface{})
@@ -1,13 +1,17 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	//mysql
+	//数据库驱动，sqlite见sqlite.go
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/go-xorm/cachestore"
@@ -18,6 +22,7 @@ import (
 	"github.com/hsyan2008/hfw2/configs"
 	"github.com/hsyan2008/hfw2/db/cache"
 	"github.com/hsyan2008/hfw2/encoding"
+	_ "github.com/lib/pq"
 )
 
 var engineMap = new(sync.Map)
@@ -43,13 +48,29 @@ func InitDb(config configs.AllConfig, dbConfig configs.DbConfig) (engine xorm.En
 			isNew = isNew || isnew
 			slaves = append(slaves, slaveEngine)
 		}
-		engine, err = xorm.NewEngineGroup(engine, slaves)
+		group, err := xorm.NewEngineGroup(engine, slaves)
 		if err != nil {
 			return nil, fmt.Errorf("NewEngineGroup dbConfig: %v failed: %v", dbConfig, err)
 		}
+		//负载均衡和健康检查，见replica.go
+		newReplicaGroup(group, dbConfig)
+		engine = group
 	}
 
-	engine.SetLogger(&xormLog{})
+	xlog := &xormLog{
+		hideSQL:   dbConfig.SlowQuery > 0,
+		slowQuery: time.Duration(dbConfig.SlowQuery) * time.Millisecond,
+	}
+	if dbConfig.HideSQL != nil {
+		xlog.hideSQL = *dbConfig.HideSQL
+	}
+	if len(dbConfig.SlowQueryLog) > 0 {
+		if xlog.slowLog, err = getSlowLog(dbConfig.SlowQueryLog); err != nil {
+			return nil, err
+		}
+	}
+	engine.SetLogger(xlog)
+	//需要xorm打印sql和耗时来统计，是否输出由xormLog决定
 	engine.ShowSQL(true)
 	engine.ShowExecTime(true)
 
@@ -68,12 +89,35 @@ func InitDb(config configs.AllConfig, dbConfig configs.DbConfig) (engine xorm.En
 			engine.SetMaxOpenConns(dbConfig.MaxOpenConns)
 		}
 
-		go keepalive(engine, dbConfig.KeepAlive)
+		//InitDb可能在hfw.Run之前或者之外调用，所以直接启动，关闭的时候退出
+		go keepalive(hfw.GetSignalContext().Ctx, engine, dbConfig.KeepAlive)
 	}
 
 	return engine, nil
 }
 
+var slowLogMap = new(sync.Map)
+
+//慢查询日志文件，相对路径在APPPATH下，同一个文件只打开一次
+func getSlowLog(file string) (*log.Logger, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(hfw.APPPATH, file)
+	}
+	if l, ok := slowLogMap.Load(file); ok {
+		return l.(*log.Logger), nil
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open slow query log %s failed: %v", file, err)
+	}
+	l, loaded := slowLogMap.LoadOrStore(file, log.New(f, "", log.LstdFlags|log.Lmicroseconds))
+	if loaded {
+		_ = f.Close()
+	}
+
+	return l.(*log.Logger), nil
+}
+
 func getEngine(config configs.DbStdConfig) (engine *xorm.Engine, isNew bool, err error) {
 
 	if config.Driver == "" {
@@ -81,7 +125,7 @@ func getEngine(config configs.DbStdConfig) (engine *xorm.Engine, isNew bool, err
 		return
 	}
 
-	driver := config.Driver
+	driver := getDriverName(config.Driver)
 	dbDsn := getDbDsn(config)
 
 	if e, ok := engineMap.Load(common.Md5(dbDsn)); ok {
@@ -98,6 +142,8 @@ func getEngine(config configs.DbStdConfig) (engine *xorm.Engine, isNew bool, err
 	engineMap.Store(common.Md5(dbDsn), engine)
 	isNew = true
 
+	hfw.GetSignalContext().OnClose(fmt.Sprintf("db %s %s/%s", driver, config.Address, config.Dbname), engine.Close)
+
 	return
 }
 
@@ -114,12 +160,61 @@ func getDbDsn(dbConfig configs.DbStdConfig) string {
 		return fmt.Sprintf("odbc:user id=%s;password=%s;server=%s;port=%s;database=%s;%s",
 			dbConfig.Username, dbConfig.Password, dbConfig.Address, dbConfig.Port,
 			dbConfig.Dbname, dbConfig.Params)
+	case "postgres", "postgresql", "pgsql":
+		//Params是空格分隔的key=value，如sslmode=disable connect_timeout=5
+		//也可以和mysql一样用?sslmode=disable&connect_timeout=5
+		var str []string
+		for _, kv := range [][2]string{
+			{"host", dbConfig.Address},
+			{"port", dbConfig.Port},
+			{"user", dbConfig.Username},
+			{"password", dbConfig.Password},
+			{"dbname", dbConfig.Dbname},
+		} {
+			if len(kv[1]) > 0 {
+				str = append(str, kv[0]+"="+pqValue(kv[1]))
+			}
+		}
+		params := dbConfig.Params
+		if strings.HasPrefix(params, "?") {
+			params = strings.Replace(params[1:], "&", " ", -1)
+		}
+		if len(params) > 0 {
+			str = append(str, params)
+		}
+		return strings.Join(str, " ")
+	case "sqlite", "sqlite3":
+		//Dbname是文件路径，如data/app.db，内存库用file::memory:?cache=shared
+		//Params如?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)
+		return dbConfig.Dbname + dbConfig.Params
 	default:
 		panic("error db driver")
 	}
 
 }
 
+//配置里的驱动名转换为xorm的驱动名
+func getDriverName(driver string) string {
+	switch strings.ToLower(driver) {
+	case "postgres", "postgresql", "pgsql":
+		return "postgres"
+	case "sqlite", "sqlite3":
+		registerSqlite()
+		return "sqlite3"
+	}
+
+	return driver
+}
+
+//pq的值有空格、单引号、反斜杠或者为空的时候要用单引号括起来
+func pqValue(s string) string {
+	if len(s) > 0 && !strings.ContainsAny(s, " '\\") {
+		return s
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
 var cacherMap = new(sync.Map)
 
 func GetCacher(config configs.AllConfig, dbConfig configs.DbConfig) (cacher *xorm.LRUCacher, err error) {
@@ -176,18 +271,18 @@ func GetCacher(config configs.AllConfig, dbConfig configs.DbConfig) (cacher *xor
 	return
 }
 
-func keepalive(engine xorm.EngineInterface, long time.Duration) {
+func keepalive(ctx context.Context, engine xorm.EngineInterface, long time.Duration) {
 	if long <= 0 {
 		return
 	}
-	t := time.Tick(long * time.Second)
-	ctx := hfw.GetSignalContext()
+	t := time.NewTicker(long * time.Second)
+	defer t.Stop()
 FOR:
 	for {
 		select {
-		case <-t:
+		case <-t.C:
 			_ = engine.Ping()
-		case <-ctx.Ctx.Done():
+		case <-ctx.Done():
 			break FOR
 		}
 	}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+func TestGetDbDsn(t *testing.T) {
+	for _, v := range []struct {
+		config configs.DbStdConfig
+		dsn    string
+	}{
+		{configs.DbStdConfig{Driver: "mysql", Username: "root", Password: "pwd", Protocol: "tcp",
+			Address: "127.0.0.1", Port: "3306", Dbname: "test", Params: "?charset=utf8mb4"},
+			"root:pwd@tcp(127.0.0.1:3306)/test?charset=utf8mb4"},
+		{configs.DbStdConfig{Driver: "postgres", Username: "root", Password: "a b'c",
+			Address: "127.0.0.1", Port: "5432", Dbname: "test", Params: "?sslmode=disable&connect_timeout=5"},
+			`host=127.0.0.1 port=5432 user=root password='a b\'c' dbname=test sslmode=disable connect_timeout=5`},
+		{configs.DbStdConfig{Driver: "pgsql", Address: "/var/run/postgresql", Dbname: "test", Params: "sslmode=disable"},
+			"host=/var/run/postgresql dbname=test sslmode=disable"},
+		{configs.DbStdConfig{Driver: "sqlite", Dbname: "data/app.db", Params: "?_pragma=busy_timeout(5000)"},
+			"data/app.db?_pragma=busy_timeout(5000)"},
+	} {
+		if dsn := getDbDsn(v.config); dsn != v.dsn {
+			t.Errorf("%s dsn: %s, expect: %s", v.config.Driver, dsn, v.dsn)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package migrate
+
+//引入本包后注册以下命令，用于部署
+//  ./app -e prod migrate up [-n 1] [-dry-run] [-dir migrations]
+//  ./app -e prod migrate down [-n 1] [-dry-run]
+//  ./app -e prod migrate status
+//  ./app -e prod migrate unlock
+//使用配置里的Db，sql文件默认在APPPATH/migrations
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	hfw "github.com/hsyan2008/hfw2"
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/db"
+)
+
+func init() {
+	var (
+		dir    string
+		dryRun bool
+		n      int
+	)
+	dirFlag := func(fs *flag.FlagSet) {
+		fs.StringVar(&dir, "dir", filepath.Join(hfw.APPPATH, "migrations"), "sql migration files dir")
+	}
+	runFlags := func(defaultN int) func(fs *flag.FlagSet) {
+		return func(fs *flag.FlagSet) {
+			dirFlag(fs)
+			fs.BoolVar(&dryRun, "dry-run", false, "only print what would be executed")
+			fs.IntVar(&n, "n", defaultN, "number of migrations, 0 means all")
+		}
+	}
+
+	_ = hfw.RegisterCommand(hfw.Command{
+		Name:  "migrate up",
+		Short: "apply pending migrations",
+		Usage: "[flags]",
+		Flags: runFlags(0),
+		Run: func(args []string) error {
+			mg, err := newMigrator(dir, dryRun)
+			if err != nil {
+				return err
+			}
+			count, err := mg.Up(n)
+			fmt.Printf("-- %d migrations applied\n", count)
+			return err
+		},
+	})
+	_ = hfw.RegisterCommand(hfw.Command{
+		Name:  "migrate down",
+		Short: "roll back applied migrations, the last one by default",
+		Usage: "[flags]",
+		Flags: runFlags(1),
+		Run: func(args []string) error {
+			if n <= 0 {
+				return errors.New("n must be positive")
+			}
+			mg, err := newMigrator(dir, dryRun)
+			if err != nil {
+				return err
+			}
+			count, err := mg.Down(n)
+			fmt.Printf("-- %d migrations rolled back\n", count)
+			return err
+		},
+	})
+	_ = hfw.RegisterCommand(hfw.Command{
+		Name:  "migrate status",
+		Short: "print applied and pending migrations",
+		Usage: "[flags]",
+		Flags: dirFlag,
+		Run: func(args []string) error {
+			mg, err := newMigrator(dir, false)
+			if err != nil {
+				return err
+			}
+			list, err := mg.Status()
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+			for _, s := range list {
+				state := "pending"
+				if s.Applied {
+					state = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				if s.Missing {
+					state += ", missing"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, state)
+			}
+			return tw.Flush()
+		},
+	})
+	_ = hfw.RegisterCommand(hfw.Command{
+		Name:  "migrate unlock",
+		Short: "release the migration lock left by a crashed process",
+		Run: func(args []string) error {
+			mg, err := newMigrator("", false)
+			if err != nil {
+				return err
+			}
+			return mg.ForceUnlock()
+		},
+	})
+}
+
+func newMigrator(dir string, dryRun bool) (*Migrator, error) {
+	if hfw.Config.Db.Driver == "" {
+		return nil, errors.New("nil db config")
+	}
+	if len(dir) > 0 && common.IsDir(dir) {
+		if err := LoadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	engine, err := db.InitDb(hfw.Config, hfw.Config.Db)
+	if err != nil {
+		return nil, err
+	}
+	mg := New(engine)
+	mg.DryRun = dryRun
+
+	return mg, nil
+}
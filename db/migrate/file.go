@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//sql文件名是 版本_名字.up.sql 和 版本_名字.down.sql，如
+//20190301120000_create_user.up.sql
+//20190301120000_create_user.down.sql
+//没有down文件的不能回滚
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+//LoadDir 加载目录下的sql文件并注册
+func LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	list := make(map[int64]*Migration)
+	var versions []int64
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		match := fileRegexp.FindStringSubmatch(f.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migration file %s: %v", f.Name(), err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+
+		m, ok := list[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			list[version] = m
+			versions = append(versions, version)
+		} else if m.Name != match[2] {
+			return fmt.Errorf("migration version %d has different names: %s, %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = splitSQL(string(b))
+		} else {
+			m.DownSQL = splitSQL(string(b))
+		}
+	}
+
+	//先检查，避免注册了一部分
+	for _, version := range versions {
+		if len(list[version].UpSQL) == 0 {
+			return fmt.Errorf("migration %s has no up file", list[version].String())
+		}
+	}
+	for _, version := range versions {
+		if err := Register(*list[version]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//按行尾的分号拆分语句，去掉--开头的注释行
+//语句中间(如存储过程)有行尾分号的不支持
+func splitSQL(s string) (stmts []string) {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.HasSuffix(line, ";") {
+			if stmt := strings.TrimSpace(strings.TrimSuffix(strings.Join(lines, "\n"), ";")); len(stmt) > 0 {
+				stmts = append(stmts, stmt)
+			}
+			lines = nil
+		}
+	}
+	if stmt := strings.TrimSpace(strings.Join(lines, "\n")); len(stmt) > 0 {
+		stmts = append(stmts, stmt)
+	}
+
+	return
+}
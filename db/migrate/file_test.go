@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSQL(t *testing.T) {
+	stmts := splitSQL(`-- create user
+CREATE TABLE user (
+	id INT NOT NULL;
+);
+INSERT INTO user VALUES (1);
+UPDATE user SET id = 2`)
+	expect := []string{"CREATE TABLE user (\n\tid INT NOT NULL", ")", "INSERT INTO user VALUES (1)", "UPDATE user SET id = 2"}
+	if !reflect.DeepEqual(stmts, expect) {
+		t.Fatalf("%q", stmts)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"2_add_name.up.sql":    "ALTER TABLE t ADD name VARCHAR(10);",
+		"2_add_name.down.sql":  "ALTER TABLE t DROP name;",
+		"1_create_t.up.sql":    "CREATE TABLE t (id INT);",
+		"readme.md":            "ignored",
+		"3_only_down.down.sql": "SELECT 1;",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = LoadDir(dir); err == nil {
+		t.Fatal("migration without up should fail")
+	}
+	os.Remove(filepath.Join(dir, "3_only_down.down.sql"))
+	if err = LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	list := getMigrations()
+	if len(list) != 2 || list[0].String() != "1_create_t" || list[0].reversible() || !list[1].reversible() {
+		t.Fatalf("%v", list)
+	}
+	if err = LoadDir(dir); err == nil {
+		t.Fatal("duplicate version should fail")
+	}
+}
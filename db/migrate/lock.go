@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	hfw "github.com/hsyan2008/hfw2"
+)
+
+//DefaultLockWait 等待其他进程释放锁的默认时间
+const DefaultLockWait = 60 * time.Second
+
+//多个实例同时部署的时候，只有拿到锁的执行迁移
+//锁是lock表里id为1的记录，插入成功表示拿到锁，不依赖数据库的锁函数
+type schemaMigrationLock struct {
+	Id       int64     `xorm:"not null pk BIGINT(20)"`
+	Owner    string    `xorm:"not null default '' VARCHAR(255)"`
+	LockedAt time.Time `xorm:"not null DATETIME"`
+}
+
+func (l *schemaMigrationLock) TableName() string {
+	return TableName + "_lock"
+}
+
+func lockOwner() string {
+	return fmt.Sprintf("%s:%d", hfw.HOSTNAME, os.Getpid())
+}
+
+func (mg *Migrator) lock() (err error) {
+	if err = mg.engine.Sync2(new(schemaMigrationLock)); err != nil {
+		return
+	}
+
+	deadline := time.Now().Add(mg.LockWait)
+	for {
+		_, err = mg.engine.Insert(&schemaMigrationLock{Id: 1, Owner: lockOwner(), LockedAt: time.Now()})
+		if err == nil {
+			return
+		}
+		//插入失败可能是锁已被占用，也可能是其他错误
+		holder := new(schemaMigrationLock)
+		has, e := mg.engine.ID(1).Get(holder)
+		if e != nil {
+			return e
+		}
+		if !has {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("migration locked by %s at %s, run `migrate unlock` if the lock is stale",
+				holder.Owner, holder.LockedAt.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Fprintf(mg.Out, "-- waiting for migration lock held by %s\n", holder.Owner)
+		time.Sleep(time.Second)
+	}
+}
+
+func (mg *Migrator) unlock() error {
+	_, err := mg.engine.Where(mg.engine.Dialect().Quote("owner")+" = ?", lockOwner()).Delete(new(schemaMigrationLock))
+	return err
+}
+
+//ForceUnlock 强制释放锁，用于执行迁移的进程异常退出后
+func (mg *Migrator) ForceUnlock() error {
+	if has, err := mg.engine.IsTableExist(new(schemaMigrationLock)); err != nil || !has {
+		return err
+	}
+	_, err := mg.engine.ID(1).Delete(new(schemaMigrationLock))
+	return err
+}
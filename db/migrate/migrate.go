@@ -0,0 +1,316 @@
+//Package migrate 数据库版本迁移
+//迁移可以是go代码或者sql文件，按版本号从小到大执行，执行过的版本记录在schema_migrations表
+//go代码在init里注册
+//func init() {
+//	migrate.Register(migrate.Migration{
+//		Version: 20190301120000,
+//		Name:    "add_user_email",
+//		Up: func(tx *db.XormDao) error {
+//			_, err := tx.Exec("ALTER TABLE user ADD email VARCHAR(64) NOT NULL DEFAULT ''")
+//			return err
+//		},
+//		Down: func(tx *db.XormDao) error {
+//			_, err := tx.Exec("ALTER TABLE user DROP email")
+//			return err
+//		},
+//	})
+//}
+//sql文件放在migrations目录，见LoadDir
+//命令行执行，见command.go
+//  ./app -e prod migrate up
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/db"
+)
+
+//TableName 记录已执行版本的表
+const TableName = "schema_migrations"
+
+//Migration 一个版本的迁移，Up/Down和UpSQL/DownSQL二选一
+type Migration struct {
+	//版本号，一般用时间，如20190301120000
+	Version int64
+	Name    string
+
+	Up   func(tx *db.XormDao) error
+	Down func(tx *db.XormDao) error
+
+	//sql文件里的语句
+	UpSQL   []string
+	DownSQL []string
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+func (m *Migration) isGo() bool {
+	return m.Up != nil
+}
+
+func (m *Migration) reversible() bool {
+	if m.isGo() {
+		return m.Down != nil
+	}
+	return len(m.DownSQL) > 0
+}
+
+var migrations = struct {
+	l    *sync.RWMutex
+	list map[int64]*Migration
+}{
+	l:    new(sync.RWMutex),
+	list: make(map[int64]*Migration),
+}
+
+//Register 注册迁移，版本号不能重复
+func Register(m Migration) error {
+	if m.Version <= 0 {
+		return errors.New("migration version must be positive")
+	}
+	if m.Up == nil && len(m.UpSQL) == 0 {
+		return fmt.Errorf("migration %s has no up", m.String())
+	}
+	if m.Up != nil && len(m.UpSQL) > 0 {
+		return fmt.Errorf("migration %s has both go and sql up", m.String())
+	}
+
+	migrations.l.Lock()
+	defer migrations.l.Unlock()
+	if v, ok := migrations.list[m.Version]; ok {
+		return fmt.Errorf("migration version %d has registered by %s", m.Version, v.String())
+	}
+	migrations.list[m.Version] = &m
+
+	return nil
+}
+
+//按版本排序
+func getMigrations() []*Migration {
+	migrations.l.RLock()
+	defer migrations.l.RUnlock()
+	list := make([]*Migration, 0, len(migrations.list))
+	for _, m := range migrations.list {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list
+}
+
+//SchemaMigration schema_migrations表的记录
+type SchemaMigration struct {
+	Version   int64     `xorm:"not null pk BIGINT(20)"`
+	Name      string    `xorm:"not null default '' VARCHAR(255)"`
+	AppliedAt time.Time `xorm:"not null DATETIME"`
+}
+
+func (m *SchemaMigration) TableName() string {
+	return TableName
+}
+
+//Status 迁移的状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	//表里有记录但是没有注册，可能是代码回退了
+	Missing bool
+}
+
+//Migrator 执行迁移
+type Migrator struct {
+	engine xorm.EngineInterface
+	dao    *db.XormDao
+	//只打印要执行的sql，不执行
+	DryRun bool
+	//执行过程输出，默认os.Stdout
+	Out io.Writer
+	//等待其他进程释放锁的时间，默认DefaultLockWait
+	LockWait time.Duration
+}
+
+//New engine一般是db.InitDb返回的
+//有从库的时候只用主库，迁移表和锁的读写都不能走从库
+func New(engine xorm.EngineInterface) *Migrator {
+	if eg, ok := engine.(*xorm.EngineGroup); ok {
+		engine = eg.Master()
+	}
+	return &Migrator{
+		engine:   engine,
+		dao:      db.NewXormDaoWithEngine(engine),
+		Out:      os.Stdout,
+		LockWait: DefaultLockWait,
+	}
+}
+
+//已执行的版本，表不存在的时候为空
+func (mg *Migrator) applied() (rs map[int64]SchemaMigration, err error) {
+	rs = make(map[int64]SchemaMigration)
+	has, err := mg.engine.IsTableExist(TableName)
+	if err != nil || !has {
+		return
+	}
+	var list []SchemaMigration
+	if err = mg.engine.Find(&list); err != nil {
+		return
+	}
+	for _, v := range list {
+		rs[v.Version] = v
+	}
+
+	return
+}
+
+//Status 所有迁移的状态，按版本排序
+func (mg *Migrator) Status() (list []Status, err error) {
+	applied, err := mg.applied()
+	if err != nil {
+		return
+	}
+	for _, m := range getMigrations() {
+		s := Status{Version: m.Version, Name: m.Name}
+		if v, ok := applied[m.Version]; ok {
+			s.Applied, s.AppliedAt = true, v.AppliedAt
+			delete(applied, m.Version)
+		}
+		list = append(list, s)
+	}
+	for _, v := range applied {
+		list = append(list, Status{Version: v.Version, Name: v.Name, Applied: true, AppliedAt: v.AppliedAt, Missing: true})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return
+}
+
+//Up 按顺序执行未执行的迁移，n<=0表示全部，返回执行的数量
+func (mg *Migrator) Up(n int) (count int, err error) {
+	return mg.migrate(n, true)
+}
+
+//Down 按倒序回滚已执行的迁移，n<=0表示1个，返回回滚的数量
+func (mg *Migrator) Down(n int) (count int, err error) {
+	if n <= 0 {
+		n = 1
+	}
+	return mg.migrate(n, false)
+}
+
+func (mg *Migrator) migrate(n int, up bool) (count int, err error) {
+	if !mg.DryRun {
+		if err = mg.engine.Sync2(new(SchemaMigration)); err != nil {
+			return
+		}
+		if err = mg.lock(); err != nil {
+			return
+		}
+		defer func() {
+			if e := mg.unlock(); e != nil {
+				logger.Error("migrate unlock error:", e)
+			}
+		}()
+	}
+
+	//加锁后再查，避免执行其他进程已经执行过的
+	applied, err := mg.applied()
+	if err != nil {
+		return
+	}
+	var todo []*Migration
+	if up {
+		for _, m := range getMigrations() {
+			if _, ok := applied[m.Version]; !ok {
+				todo = append(todo, m)
+			}
+		}
+	} else {
+		list := getMigrations()
+		for i := len(list) - 1; i >= 0; i-- {
+			if _, ok := applied[list[i].Version]; ok {
+				todo = append(todo, list[i])
+			}
+		}
+	}
+	if n > 0 && len(todo) > n {
+		todo = todo[:n]
+	}
+
+	for _, m := range todo {
+		if err = mg.run(m, up); err != nil {
+			return
+		}
+		count++
+	}
+
+	return
+}
+
+func (mg *Migrator) run(m *Migration, up bool) error {
+	direction := "up"
+	stmts, f := m.UpSQL, m.Up
+	if !up {
+		direction = "down"
+		stmts, f = m.DownSQL, m.Down
+		if !m.reversible() {
+			return fmt.Errorf("migration %s is irreversible", m.String())
+		}
+	}
+
+	fmt.Fprintf(mg.Out, "-- %s %s\n", direction, m.String())
+	if mg.DryRun {
+		if f != nil {
+			fmt.Fprintln(mg.Out, "-- go func, can not print sql")
+		}
+		for _, s := range stmts {
+			fmt.Fprintf(mg.Out, "%s;\n", s)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	//mysql的ddl会隐式提交，失败时已执行的ddl不能回滚，一个迁移最好只有一个ddl
+	err := mg.dao.Transaction(nil, func(tx *db.XormDao) (err error) {
+		if f != nil {
+			err = f(tx)
+		} else {
+			for _, s := range stmts {
+				if _, err = tx.Exec(s); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+		if up {
+			_, err = tx.Insert(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()})
+		} else {
+			_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?",
+				mg.engine.Dialect().Quote(TableName), mg.engine.Dialect().Quote("version")), m.Version)
+		}
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("migrate %s %s failed: %v", direction, m.String(), err)
+	}
+	fmt.Fprintf(mg.Out, "-- %s %s done in %s\n", direction, m.String(), time.Since(start))
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-xorm/xorm"
+	"github.com/hsyan2008/hfw2/configs"
+	"github.com/hsyan2008/hfw2/db"
+)
+
+//有从库的时候迁移表和锁都在主库上读写
+func TestMigrateWithSlaves(t *testing.T) {
+	dir := t.TempDir()
+	engine, err := db.InitDb(configs.AllConfig{}, configs.DbConfig{
+		DbStdConfig: configs.DbStdConfig{Driver: "sqlite", Dbname: filepath.Join(dir, "master.db")},
+		Slaves:      []configs.DbStdConfig{{Driver: "sqlite", Dbname: filepath.Join(dir, "slave.db")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eg, ok := engine.(*xorm.EngineGroup)
+	if !ok {
+		t.Fatalf("want *xorm.EngineGroup, got %T", engine)
+	}
+
+	old := migrations.list
+	defer func() {
+		migrations.list = old
+	}()
+	migrations.list = make(map[int64]*Migration)
+	_ = Register(Migration{Version: 1, Name: "create_t", UpSQL: []string{"CREATE TABLE t (id INT)"}, DownSQL: []string{"DROP TABLE t"}})
+	_ = Register(Migration{Version: 2, Name: "insert_t", UpSQL: []string{"INSERT INTO t VALUES (1)"}, DownSQL: []string{"DELETE FROM t"}})
+
+	mg := New(engine)
+	mg.Out = new(bytes.Buffer)
+	if n, err := mg.Up(1); n != 1 || err != nil {
+		t.Fatalf("Up(1) want 1, got %d %v", n, err)
+	}
+	//从库没有迁移表，读从库的话会认为都没执行
+	list, err := mg.Status()
+	if err != nil || len(list) != 2 || !list[0].Applied || list[1].Applied {
+		t.Fatalf("want version 1 applied, got %+v %v", list, err)
+	}
+	if n, err := mg.Up(0); n != 1 || err != nil {
+		t.Fatalf("Up(0) want 1, got %d %v", n, err)
+	}
+	if has, _ := eg.Slaves()[0].IsTableExist(TableName); has {
+		t.Fatal("migration should not run on slave")
+	}
+
+	//锁被其他进程持有
+	if _, err = eg.Master().Insert(&schemaMigrationLock{Id: 1, Owner: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	mg.LockWait = 0
+	if _, err = mg.Down(1); err == nil {
+		t.Fatal("want locked error")
+	}
+	if err = mg.ForceUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := mg.Down(2); n != 2 || err != nil {
+		t.Fatalf("Down(2) want 2, got %d %v", n, err)
+	}
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	logger "github.com/hsyan2008/go-logger"
+)
+
+//Pagination 分页信息
+type Pagination struct {
+	Total     int64 `json:"total"`
+	Page      int   `json:"page"`
+	PageSize  int   `json:"page_size"`
+	PageCount int   `json:"page_count"`
+}
+
+//Paginate 按cond里的page和pagesize查询到t，t必须是slice的指针，同时返回总数等分页信息
+func (d *XormDao) Paginate(t interface{}, cond Cond) (p Pagination, err error) {
+	bean, err := newSliceElem(t)
+	if err != nil {
+		return
+	}
+	p.Total, err = d.Count(bean, cond)
+	if err != nil {
+		return
+	}
+
+	p.Page, p.PageSize = 1, DefaultPageSize
+	for k, v := range cond {
+		switch strings.ToLower(k) {
+		case "page":
+			if v.(int) > 1 {
+				p.Page = v.(int)
+			}
+		case "pagesize":
+			if v.(int) > 0 {
+				p.PageSize = v.(int)
+			}
+		}
+	}
+	p.PageCount = int((p.Total + int64(p.PageSize) - 1) / int64(p.PageSize))
+
+	//超出范围的页不用查
+	if p.Page > p.PageCount {
+		return
+	}
+	err = d.Search(t, cond)
+
+	return
+}
+
+//CursorOptions 游标分页的参数
+type CursorOptions struct {
+	//游标列，必须唯一且有索引，默认id
+	Column string
+	//升序，默认降序
+	Asc bool
+	//上一页返回的next，第一页为nil
+	Cursor   interface{}
+	PageSize int
+}
+
+//SearchByCursor 按游标列分页查询到t，用where column < cursor代替offset，适合大表翻页
+//返回下一页的游标，没有下一页返回nil，cond里的orderby、page会被忽略
+func (d *XormDao) SearchByCursor(t interface{}, cond Cond, opt CursorOptions) (next interface{}, err error) {
+	rv := reflect.ValueOf(t)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("SearchByCursor need pointer of slice")
+	}
+	if len(opt.Column) == 0 {
+		opt.Column = "id"
+	}
+	opt.Column = strings.ToLower(opt.Column)
+	if !columnRegexp.MatchString(opt.Column) {
+		return nil, fmt.Errorf("invalid cursor column %q", opt.Column)
+	}
+	if opt.PageSize <= 0 {
+		opt.PageSize = DefaultPageSize
+	}
+
+	c := make(Cond, len(cond)+4)
+	for k, v := range cond {
+		switch strings.ToLower(k) {
+		case "orderby", "page", "pagesize":
+		default:
+			c[k] = v
+		}
+	}
+	op, order := "<", "desc"
+	if opt.Asc {
+		op, order = ">", "asc"
+	}
+	if opt.Cursor != nil {
+		//避免和cond里相同列的条件冲突
+		c[opt.Column+" "+op+" "] = opt.Cursor
+	}
+	c["orderby"] = quoteColumn(d.engine.Dialect().Quote, opt.Column) + " " + order
+	//多查一条判断是否有下一页
+	c["pagesize"] = opt.PageSize + 1
+
+	if err = d.Search(t, c); err != nil {
+		return
+	}
+
+	slice := rv.Elem()
+	if slice.Len() <= opt.PageSize {
+		return nil, nil
+	}
+	slice.Set(slice.Slice(0, opt.PageSize))
+	last := reflect.Indirect(slice.Index(opt.PageSize - 1))
+	field := d.cursorField(last, opt.Column)
+	if !field.IsValid() {
+		err = fmt.Errorf("cursor column %s not found in %s", opt.Column, last.Type())
+		logger.Error(err)
+		return
+	}
+
+	return field.Interface(), nil
+}
+
+//按列名找struct的字段，带表名的取列名
+func (d *XormDao) cursorField(v reflect.Value, column string) reflect.Value {
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		column = column[i+1:]
+	}
+	field := v.FieldByName(d.engine.GetColumnMapper().Table2Obj(column))
+	if field.IsValid() {
+		return field
+	}
+	name := strings.Replace(column, "_", "", -1)
+
+	return v.FieldByNameFunc(func(s string) bool {
+		return strings.EqualFold(s, name)
+	})
+}
+
+//slice元素的指针，用于Count
+func newSliceElem(t interface{}) (interface{}, error) {
+	rt := reflect.TypeOf(t)
+	if rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("Paginate need pointer of slice")
+	}
+	rt = rt.Elem().Elem()
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	return reflect.New(rt).Interface(), nil
+}
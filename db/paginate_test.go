@@ -0,0 +1,94 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	d := newSqliteDao(t, 25)
+
+	for _, v := range []struct {
+		cond  Cond
+		p     Pagination
+		count int
+	}{
+		{Cond{"page": 1, "pagesize": 10}, Pagination{Total: 25, Page: 1, PageSize: 10, PageCount: 3}, 10},
+		//最后一页不满
+		{Cond{"page": 3, "pagesize": 10}, Pagination{Total: 25, Page: 3, PageSize: 10, PageCount: 3}, 5},
+		//超出范围的页
+		{Cond{"page": 4, "pagesize": 10}, Pagination{Total: 25, Page: 4, PageSize: 10, PageCount: 3}, 0},
+		{Cond{"page": 2, "pagesize": 10, "age >": 20}, Pagination{Total: 5, Page: 2, PageSize: 10, PageCount: 1}, 0},
+		//没有数据
+		{Cond{"age >": 100}, Pagination{Total: 0, Page: 1, PageSize: DefaultPageSize, PageCount: 0}, 0},
+	} {
+		var users []testUser
+		p, err := d.Paginate(&users, v.cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != v.p || len(users) != v.count {
+			t.Errorf("cond %v: want %+v %d rows, got %+v %d rows", v.cond, v.p, v.count, p, len(users))
+		}
+	}
+
+	if _, err := d.Paginate(new(testUser), Cond{}); err == nil {
+		t.Fatal("Paginate need pointer of slice")
+	}
+}
+
+func TestSearchByCursor(t *testing.T) {
+	d := newSqliteDao(t, 25)
+
+	search := func(cond Cond, opt CursorOptions) (ids []int, pages int) {
+		for {
+			var users []*testUser
+			next, err := d.SearchByCursor(&users, cond, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages++
+			for _, u := range users {
+				ids = append(ids, u.Id)
+			}
+			if next == nil {
+				return
+			}
+			opt.Cursor = next
+		}
+	}
+
+	//默认按id降序，最后一页不满
+	ids, pages := search(Cond{"age <=": 20, "orderby": "age asc"}, CursorOptions{PageSize: 7})
+	if pages != 3 || len(ids) != 20 || ids[0] != 20 || ids[19] != 1 {
+		t.Fatalf("desc: want 20 ids from 20 to 1 in 3 pages, got %v in %d pages", ids, pages)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] >= ids[i-1] {
+			t.Fatalf("desc: not in order %v", ids)
+		}
+	}
+
+	//刚好整页的时候最后一页返回nil
+	ids, pages = search(Cond{"age <=": 20}, CursorOptions{PageSize: 10, Asc: true})
+	if pages != 2 || len(ids) != 20 || ids[0] != 1 || ids[19] != 20 {
+		t.Fatalf("asc: want 20 ids from 1 to 20 in 2 pages, got %v in %d pages", ids, pages)
+	}
+
+	//按其他列
+	ids, _ = search(Cond{"age >": 22}, CursorOptions{Column: "age", PageSize: 2})
+	if !reflect.DeepEqual(ids, []int{25, 24, 23}) {
+		t.Fatalf("age cursor: got %v", ids)
+	}
+
+	//没有数据
+	var users []testUser
+	next, err := d.SearchByCursor(&users, Cond{"age >": 100}, CursorOptions{})
+	if err != nil || next != nil || len(users) != 0 {
+		t.Fatalf("empty: got %v %v %d", next, err, len(users))
+	}
+
+	if _, err = d.SearchByCursor(&users, Cond{}, CursorOptions{Column: "id;drop"}); err == nil {
+		t.Fatal("invalid cursor column should fail")
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+import "fmt"
+
+//RbacLoader 从数据库加载角色权限，实现了hfw.RbacLoader
+//表至少包含role和permission两列，每行一个权限
+type RbacLoader struct {
+	dao   *XormDao
+	table string
+}
+
+//NewRbacLoader 用法：hfw.LoadRbac(db.NewRbacLoader(dao, "rbac"))
+func NewRbacLoader(dao *XormDao, table string) *RbacLoader {
+	return &RbacLoader{
+		dao:   dao,
+		table: table,
+	}
+}
+
+func (l *RbacLoader) LoadRbac() (m map[string][]string, err error) {
+	if !columnRegexp.MatchString(l.table) {
+		return nil, fmt.Errorf("invalid rbac table name %q", l.table)
+	}
+	rs, err := l.dao.QueryString(fmt.Sprintf("SELECT %s, %s FROM %s",
+		l.dao.Quote("role"), l.dao.Quote("permission"), quoteColumn(l.dao.Quote, l.table)))
+	if err != nil {
+		return
+	}
+
+	m = make(map[string][]string)
+	for _, v := range rs {
+		m[v["role"]] = append(m[v["role"]], v["permission"])
+	}
+
+	return
+}
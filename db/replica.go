@@ -0,0 +1,278 @@
+package db
+
+//读写分离
+//配置了Slaves的时候InitDb返回*xorm.EngineGroup，写和事务走主库，普通的读走从库
+//以下情况读也走主库
+//  dao.Master().SearchOne(&user, cond)
+//  dao.WithContext(db.UseMaster(ctx)).Search(&users, cond)
+//  ctx是db.WithReadYourWrites生成的，并且用这个ctx写过数据，在Db.ReadYourWrites毫秒内
+//  配置了Db.ReadYourWrites的时候，每个请求的httpCtx.Ctx已经调用过WithReadYourWrites
+//  需要用dao.WithContext(httpCtx.Ctx)执行sql，同一个请求里写了之后的读才会走主库
+//  没有ctx或者ctx没有WithReadYourWrites的时候，记录在dao上，同一个dao(如同一个model)写了之后的读也走主库
+//  所有从库都被健康检查摘除了
+//注意NewSession的时候就决定了走主库还是从库
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	logger "github.com/hsyan2008/go-logger"
+	hfw "github.com/hsyan2008/hfw2"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//连续失败多少次后摘除从库
+const defaultHealthCheckFails = 3
+
+type replicaCtxKey int
+
+const (
+	useMasterKey replicaCtxKey = iota
+	readYourWritesKey
+)
+
+//UseMaster 返回的ctx给dao用的时候，读走主库
+func UseMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, useMasterKey, true)
+}
+
+//最后一次写的时间，UnixNano
+type writeMark struct {
+	last int64
+}
+
+func init() {
+	//有配置了ReadYourWrites的从库的时候，给每个请求的ctx加上
+	hfw.RegisterContextHook(func(ctx context.Context) context.Context {
+		if atomic.LoadInt32(&readYourWritesEnabled) == 0 {
+			return ctx
+		}
+		return WithReadYourWrites(ctx)
+	})
+}
+
+//WithReadYourWrites 用这个ctx写过数据之后，Db.ReadYourWrites毫秒内的读都走主库，避免从库延迟读不到刚写的数据
+//http请求的httpCtx.Ctx已经自动调用，其他地方如worker里需要自己调用，如
+//ctx = db.WithReadYourWrites(ctx)
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(readYourWritesKey).(*writeMark); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, readYourWritesKey, new(writeMark))
+}
+
+//Master 返回读走主库的dao，和原dao共用session
+func (d *XormDao) Master() *XormDao {
+	dao := *d
+	dao.master = true
+
+	return &dao
+}
+
+//写之后调用，记录到ctx里，ctx没有WithReadYourWrites的时候记录到dao里
+func (d *XormDao) markWrite() {
+	if m := d.writeMark(); m != nil {
+		atomic.StoreInt64(&m.last, time.Now().UnixNano())
+	}
+}
+
+func (d *XormDao) writeMark() *writeMark {
+	if d.ctx != nil {
+		if m, ok := d.ctx.Value(readYourWritesKey).(*writeMark); ok {
+			return m
+		}
+	}
+
+	return d.mark
+}
+
+//是否需要读主库
+func (d *XormDao) readMaster(eg *xorm.EngineGroup) bool {
+	if d.master {
+		return true
+	}
+	g, ok := replicaGroupMap.Load(eg)
+	if !ok {
+		return false
+	}
+	group := g.(*replicaGroup)
+	if !group.hasHealthy() {
+		return true
+	}
+	if d.ctx != nil {
+		if b, _ := d.ctx.Value(useMasterKey).(bool); b {
+			return true
+		}
+	}
+	if m := d.writeMark(); m != nil && group.window > 0 {
+		last := atomic.LoadInt64(&m.last)
+		return last > 0 && time.Since(time.Unix(0, last)) < group.window
+	}
+
+	return false
+}
+
+//从库的健康状态，多个EngineGroup共用同一个从库engine的时候也共用
+type replica struct {
+	engine  *xorm.Engine
+	name    string
+	fails   int
+	ejected int32
+}
+
+func (r *replica) isEjected() bool {
+	return atomic.LoadInt32(&r.ejected) == 1
+}
+
+//ping失败maxFails次后摘除，摘除后成功一次就加回来
+func (r *replica) check(ctx context.Context, timeout time.Duration, maxFails int) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	//不用engine.Ping，避免每次都打印日志
+	err := r.engine.DB().PingContext(ctx)
+	if err == nil {
+		r.fails = 0
+		if atomic.CompareAndSwapInt32(&r.ejected, 1, 0) {
+			logger.Infof("db slave %s recovered, rejoin", r.name)
+		}
+		return
+	}
+	r.fails++
+	if r.fails >= maxFails && atomic.CompareAndSwapInt32(&r.ejected, 0, 1) {
+		logger.Warnf("db slave %s ping failed %d times, eject: %v", r.name, r.fails, err)
+	}
+}
+
+func (r *replica) healthCheck(ctx context.Context, long time.Duration, maxFails int) {
+	t := time.NewTicker(long)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.check(ctx, long, maxFails)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+var (
+	replicaMap      = new(sync.Map)
+	replicaGroupMap = new(sync.Map)
+	//有配置了ReadYourWrites的从库
+	readYourWritesEnabled int32
+)
+
+//replicaGroup 实现xorm.GroupPolicy，只在没有被摘除的从库里选
+type replicaGroup struct {
+	policy  string
+	window  time.Duration
+	slaves  []*replica
+	weights []int
+	pos     uint64
+}
+
+func newReplicaGroup(eg *xorm.EngineGroup, dbConfig configs.DbConfig) *replicaGroup {
+	group := &replicaGroup{
+		policy: strings.ToLower(dbConfig.Policy),
+		window: time.Duration(dbConfig.ReadYourWrites) * time.Millisecond,
+	}
+	maxFails := dbConfig.HealthCheckFails
+	if maxFails <= 0 {
+		maxFails = defaultHealthCheckFails
+	}
+	for i, slave := range eg.Slaves() {
+		r, loaded := replicaMap.LoadOrStore(slave, &replica{
+			engine: slave,
+			name:   fmt.Sprintf("%s/%s", dbConfig.Slaves[i].Address, dbConfig.Slaves[i].Dbname),
+		})
+		rep := r.(*replica)
+		//和keepalive一样直接启动，关闭的时候退出
+		if !loaded && dbConfig.HealthCheck > 0 {
+			go rep.healthCheck(hfw.GetSignalContext().Ctx, dbConfig.HealthCheck*time.Second, maxFails)
+		}
+		weight := dbConfig.Slaves[i].Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		group.slaves = append(group.slaves, rep)
+		group.weights = append(group.weights, weight)
+	}
+	eg.SetPolicy(group)
+	replicaGroupMap.Store(eg, group)
+	if group.window > 0 {
+		atomic.StoreInt32(&readYourWritesEnabled, 1)
+	}
+
+	return group
+}
+
+func (g *replicaGroup) hasHealthy() bool {
+	for _, r := range g.slaves {
+		if !r.isEjected() {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Slave 没有可用的从库返回主库
+//只有一个从库的时候xorm不会调用这里，从库被摘除的时候由readMaster走主库
+func (g *replicaGroup) Slave(eg *xorm.EngineGroup) *xorm.Engine {
+	var (
+		slaves  []*replica
+		weights []int
+		total   int
+	)
+	for i, r := range g.slaves {
+		if !r.isEjected() {
+			slaves = append(slaves, r)
+			weights = append(weights, g.weights[i])
+			total += g.weights[i]
+		}
+	}
+	if len(slaves) == 0 {
+		return eg.Master()
+	}
+
+	switch g.policy {
+	case "random":
+		return slaves[rand.Intn(len(slaves))].engine
+	case "weightrandom":
+		return slaves[pickWeight(weights, rand.Intn(total))].engine
+	case "weightroundrobin":
+		n := atomic.AddUint64(&g.pos, 1) - 1
+		return slaves[pickWeight(weights, int(n%uint64(total)))].engine
+	case "leastconn":
+		idx, min := 0, -1
+		for i, r := range slaves {
+			if n := r.engine.DB().Stats().OpenConnections; min < 0 || n < min {
+				idx, min = i, n
+			}
+		}
+		return slaves[idx].engine
+	default:
+		n := atomic.AddUint64(&g.pos, 1) - 1
+		return slaves[n%uint64(len(slaves))].engine
+	}
+}
+
+//n在[0, 权重和)之间，返回n落在哪个权重里
+func pickWeight(weights []int, n int) int {
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+
+	return len(weights) - 1
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+func TestReplicaGroupSlave(t *testing.T) {
+	a, b, c := &replica{engine: new(xorm.Engine)}, &replica{engine: new(xorm.Engine)}, &replica{engine: new(xorm.Engine)}
+	g := &replicaGroup{
+		policy:  "weightroundrobin",
+		slaves:  []*replica{a, b, c},
+		weights: []int{1, 2, 1},
+	}
+	eg := new(xorm.EngineGroup)
+
+	count := make(map[*xorm.Engine]int)
+	for i := 0; i < 8; i++ {
+		count[g.Slave(eg)]++
+	}
+	if count[a.engine] != 2 || count[b.engine] != 4 || count[c.engine] != 2 {
+		t.Fatalf("weightroundrobin want 2,4,2 got %d,%d,%d", count[a.engine], count[b.engine], count[c.engine])
+	}
+
+	b.ejected = 1
+	g.policy = "roundrobin"
+	for i := 0; i < 4; i++ {
+		if g.Slave(eg) == b.engine {
+			t.Fatal("ejected slave should not be chosen")
+		}
+	}
+
+	a.ejected, c.ejected = 1, 1
+	if g.hasHealthy() || g.Slave(eg) != eg.Master() {
+		t.Fatal("all slaves ejected, want master")
+	}
+}
+
+func TestReadYourWritesWithoutContext(t *testing.T) {
+	dir := t.TempDir()
+	std := func(name string) configs.DbStdConfig {
+		return configs.DbStdConfig{Driver: "sqlite", Dbname: filepath.Join(dir, name)}
+	}
+	dbConfig := configs.DbConfig{
+		DbStdConfig:    std("master.db"),
+		Slaves:         []configs.DbStdConfig{std("slave.db")},
+		ReadYourWrites: 100,
+	}
+	engine, err := InitDb(configs.AllConfig{}, dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		replicaGroupMap.Delete(engine)
+		for _, c := range []configs.DbStdConfig{dbConfig.DbStdConfig, dbConfig.Slaves[0]} {
+			if e, ok := engineMap.Load(common.Md5(getDbDsn(c))); ok {
+				engineMap.Delete(common.Md5(getDbDsn(c)))
+				replicaMap.Delete(e)
+				_ = e.(*xorm.Engine).Close()
+			}
+		}
+	})
+	eg := engine.(*xorm.EngineGroup)
+	for _, e := range append(eg.Slaves(), eg.Master()) {
+		if err = e.Sync2(new(testUser)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//没有同步，写到主库的数据从库读不到
+	d := NewXormDaoWithEngine(engine)
+	count := func(d *XormDao) int64 {
+		total, err := d.Count(new(testUser), Cond{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+	if count(d) != 0 {
+		t.Fatal("want 0 before write")
+	}
+	if _, err = d.Insert(&testUser{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if count(d) != 1 || count(d.WithContext(context.Background())) != 1 {
+		t.Fatal("reads without ctx after write should go to master")
+	}
+	//有自己的WithReadYourWrites的ctx按ctx判断，其他dao不受影响
+	if count(d.WithContext(WithReadYourWrites(context.Background()))) != 0 {
+		t.Fatal("ctx with WithReadYourWrites should use its own mark")
+	}
+	if count(NewXormDaoWithEngine(engine)) != 0 {
+		t.Fatal("other dao should read slave")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if count(d) != 0 {
+		t.Fatal("reads after window should go to slave")
+	}
+}
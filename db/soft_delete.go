@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-xorm/core"
+	"github.com/go-xorm/xorm"
+)
+
+//软删除的列，model有IsDeleted字段(一般是嵌入了Models)的表
+//Search、SearchOne、Count、Rows、Iterate、GetMulti、UpdateByWhere自动加上is_deleted = 0
+//cond里有is_deleted条件的不自动添加，后台查询已删除的数据用Unscoped
+const softDeleteColumn = "is_deleted"
+
+//Unscoped 返回不自动过滤已删除数据的dao，和原dao共用session
+func (d *XormDao) Unscoped() *XormDao {
+	dao := *d
+	dao.unscoped = true
+
+	return &dao
+}
+
+//SoftDelete 按id软删除，t是model的指针，用于确定表
+func (d *XormDao) SoftDelete(t interface{}, ids ...interface{}) (affected int64, err error) {
+	return d.setDeleted(t, 1, ids)
+}
+
+//Restore 按id恢复软删除的数据
+func (d *XormDao) Restore(t interface{}, ids ...interface{}) (affected int64, err error) {
+	return d.setDeleted(t, 0, ids)
+}
+
+func (d *XormDao) setDeleted(t interface{}, isDeleted int, ids []interface{}) (affected int64, err error) {
+	defer d.markWrite()
+	if len(ids) == 0 {
+		return 0, errors.New("ids parameters error")
+	}
+	if !hasSoftDelete(t) {
+		return 0, fmt.Errorf("%T has no IsDeleted field", t)
+	}
+
+	sess := d.sess
+	if sess == nil {
+		sess = d.newSession()
+		defer sess.Close()
+	}
+
+	//用表名和map更新，xorm不会自动处理updated和version列，需要自己加上
+	table := d.engine.TableInfo(t)
+	m := map[string]interface{}{softDeleteColumn: isDeleted}
+	if len(table.Updated) > 0 {
+		m[table.Updated] = d.nowTime(table.UpdatedColumn())
+	}
+	sess.Table(table.Name).In("id", ids...)
+	if len(table.Version) > 0 {
+		sess.Incr(table.Version)
+	}
+	affected, err = sess.Update(m)
+	if err != nil {
+		lastSQL, lastSQLArgs := sess.LastSQL()
+		logSQLError(err, lastSQL, lastSQLArgs)
+	}
+
+	return
+}
+
+//和xorm自动设置的updated列的值一致，按DatabaseTZ格式化，数字类型的列是时间戳
+func (d *XormDao) nowTime(col *core.Column) interface{} {
+	now := time.Now()
+	if col.SQLType.IsNumeric() {
+		return now.Unix()
+	}
+	tz := time.Local
+	switch e := d.engine.(type) {
+	case *xorm.Engine:
+		tz = e.DatabaseTZ
+	case *xorm.EngineGroup:
+		tz = e.Master().DatabaseTZ
+	}
+	if tz == nil {
+		tz = time.Local
+	}
+	now = now.In(tz)
+	if col.SQLType.Name == core.Date {
+		return now.Format("2006-01-02")
+	}
+
+	return now.Format("2006-01-02 15:04:05")
+}
+
+//HardDelete 按id物理删除
+func (d *XormDao) HardDelete(t interface{}, ids ...interface{}) (affected int64, err error) {
+	defer d.markWrite()
+	if len(ids) == 0 {
+		return 0, errors.New("ids parameters error")
+	}
+
+	sess := d.sess
+	if sess == nil {
+		sess = d.newSession()
+		defer sess.Close()
+	}
+
+	affected, err = sess.In("id", ids...).NoAutoCondition().Delete(t)
+	if err != nil {
+		lastSQL, lastSQLArgs := sess.LastSQL()
+		logSQLError(err, lastSQL, lastSQLArgs)
+	}
+
+	return
+}
+
+//是否需要自动过滤已删除的数据
+func (d *XormDao) isSoftDeleteScoped(t interface{}, cond Cond) bool {
+	if d.unscoped || !hasSoftDelete(t) {
+		return false
+	}
+	for k := range cond {
+		if fields := strings.Fields(strings.ToLower(k)); len(fields) > 0 && fields[0] == softDeleteColumn {
+			return false
+		}
+	}
+
+	return true
+}
+
+//t可以是struct、slice或者它们的指针
+func hasSoftDelete(t interface{}) bool {
+	rt := reflect.TypeOf(t)
+	for rt != nil && (rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice) {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := rt.FieldByName("IsDeleted")
+
+	return ok
+}
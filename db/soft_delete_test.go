@@ -0,0 +1,80 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSoftDelete(t *testing.T) {
+	d := newSqliteDao(t, 5)
+	if _, err := d.Exec("UPDATE test_user SET updated_at = '2000-01-01 00:00:00'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := d.SoftDelete(new(testUser), 1, 2); n != 2 || err != nil {
+		t.Fatalf("SoftDelete want 2, got %d %v", n, err)
+	}
+	count := func(d *XormDao, cond Cond) int64 {
+		n, err := d.Count(new(testUser), cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(d, Cond{}); n != 3 {
+		t.Fatalf("deleted rows should be filtered, got %d", n)
+	}
+	if n := count(d.Unscoped(), Cond{}); n != 5 {
+		t.Fatalf("Unscoped want 5, got %d", n)
+	}
+	if n := count(d, Cond{"is_deleted": 1}); n != 2 {
+		t.Fatalf("cond with is_deleted want 2, got %d", n)
+	}
+	var u testUser
+	if has, _ := d.SearchOne(&u, Cond{"id": 1}); has {
+		t.Fatal("deleted row should not be found")
+	}
+	if has, _ := d.Unscoped().SearchOne(&u, Cond{"id": 1}); !has || u.IsDeleted != 1 || u.UpdatedAt.Year() == 2000 {
+		t.Fatalf("SoftDelete should set is_deleted and updated_at, got %+v", u)
+	}
+	var users []testUser
+	if err := d.GetMulti(&users, 1, 2, 3); err != nil || len(users) != 1 {
+		t.Fatalf("GetMulti want 1, got %d %v", len(users), err)
+	}
+
+	if n, err := d.Restore(new(testUser), 1); n != 1 || err != nil {
+		t.Fatalf("Restore want 1, got %d %v", n, err)
+	}
+	if n := count(d, Cond{}); n != 4 {
+		t.Fatalf("restored row should be found, got %d", n)
+	}
+
+	//软删除的和没删除的都可以物理删除
+	if n, err := d.HardDelete(new(testUser), 2, 3); n != 2 || err != nil {
+		t.Fatalf("HardDelete want 2, got %d %v", n, err)
+	}
+	if n := count(d.Unscoped(), Cond{}); n != 3 {
+		t.Fatalf("after HardDelete want 3, got %d", n)
+	}
+
+	if _, err := d.SoftDelete(new(testUser)); err == nil {
+		t.Fatal("SoftDelete without ids should fail")
+	}
+	if _, err := d.SoftDelete(&struct{ Id int }{}, 1); err == nil {
+		t.Fatal("SoftDelete model without IsDeleted should fail")
+	}
+}
+
+func TestSoftDeleteVersion(t *testing.T) {
+	d := newSqliteDao(t, 0)
+	item := &testItem{Name: "a"}
+	if _, err := d.Insert(item); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.SoftDelete(new(testItem), item.Id); n != 1 || err != nil {
+		t.Fatalf("SoftDelete want 1, got %d %v", n, err)
+	}
+	var got testItem
+	if has, _ := d.Unscoped().SearchOne(&got, Cond{"id": item.Id}); !has || got.IsDeleted != 1 || got.Version != item.Version+1 {
+		t.Fatalf("SoftDelete should bump version, got %+v", got)
+	}
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	//sqlite，纯go实现，不需要cgo
+	_ "modernc.org/sqlite"
+)
+
+var sqliteOnce = new(sync.Once)
+
+//xorm只认sqlite3的驱动名，modernc.org/sqlite注册的是sqlite
+//没有其他包(如mattn/go-sqlite3)注册sqlite3的时候，用sqlite的驱动注册为sqlite3
+func registerSqlite() {
+	sqliteOnce.Do(func() {
+		for _, name := range sql.Drivers() {
+			if name == "sqlite3" {
+				return
+			}
+		}
+		db, err := sql.Open("sqlite", "")
+		if err != nil {
+			return
+		}
+		sql.Register("sqlite3", db.Driver())
+		_ = db.Close()
+	})
+}
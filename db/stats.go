@@ -0,0 +1,168 @@
+package db
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	hfw "github.com/hsyan2008/hfw2"
+	"github.com/hsyan2008/hfw2/encoding"
+)
+
+//引入db包就注册/db/stats，和其他管理接口一样需要开启Admin.Enable
+func init() {
+	hfw.RegisterAdminHandler("/db/stats", QueryStatsHandler)
+}
+
+//MaxQueryStats 最多统计的sql指纹数量，超过的不再统计
+var MaxQueryStats = 1000
+
+//QueryStat 一类sql的统计，同一个指纹的sql只是参数不同
+type QueryStat struct {
+	Fingerprint string        `json:"fingerprint"`
+	Count       int64         `json:"count"`
+	Errors      int64         `json:"errors"`
+	Slow        int64         `json:"slow"`
+	Total       time.Duration `json:"total"`
+	Avg         time.Duration `json:"avg"`
+	Max         time.Duration `json:"max"`
+	LastSeen    time.Time     `json:"last_seen"`
+}
+
+var queryStats = struct {
+	l    *sync.Mutex
+	list map[string]*QueryStat
+	//超过MaxQueryStats没有统计的次数
+	dropped int64
+}{
+	l:    new(sync.Mutex),
+	list: make(map[string]*QueryStat),
+}
+
+var (
+	fingerprintString  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	fingerprintNumber  = regexp.MustCompile(`\b\d+(\.\d+)?\b|\$\d+`)
+	fingerprintIn      = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(\s*,\s*\?)*\s*\)`)
+	fingerprintValues  = regexp.MustCompile(`(?i)\bVALUES\s*(\(\s*\?(\s*,\s*\?)*\s*\))(\s*,\s*\(\s*\?(\s*,\s*\?)*\s*\))*`)
+	fingerprintSpace   = regexp.MustCompile(`\s+`)
+	fingerprintReplace = strings.NewReplacer("`", "", `"`, "")
+)
+
+//Fingerprint 去掉sql里的参数，用于把同一类sql归在一起
+//字符串、数字换成?，in和批量插入的多个?合并，合并空白
+func Fingerprint(sql string) string {
+	s := fingerprintString.ReplaceAllString(sql, "?")
+	s = fingerprintNumber.ReplaceAllString(s, "?")
+	s = fingerprintIn.ReplaceAllString(s, "IN (?+)")
+	s = fingerprintValues.ReplaceAllString(s, "VALUES $1+")
+	s = fingerprintSpace.ReplaceAllString(s, " ")
+
+	return strings.TrimSpace(fingerprintReplace.Replace(s))
+}
+
+func recordQuery(sql string, d time.Duration, isErr, isSlow bool) {
+	fp := Fingerprint(sql)
+
+	queryStats.l.Lock()
+	defer queryStats.l.Unlock()
+	stat, ok := queryStats.list[fp]
+	if !ok {
+		if len(queryStats.list) >= MaxQueryStats {
+			queryStats.dropped++
+			return
+		}
+		stat = &QueryStat{Fingerprint: fp}
+		queryStats.list[fp] = stat
+	}
+	//出错的sql在执行的时候已经统计过次数
+	if isErr {
+		stat.Errors++
+		return
+	}
+	stat.Count++
+	stat.Total += d
+	if d > stat.Max {
+		stat.Max = d
+	}
+	if isSlow {
+		stat.Slow++
+	}
+	stat.LastSeen = time.Now()
+}
+
+//GetQueryStats 返回sql统计，按总耗时倒序
+func GetQueryStats() (list []QueryStat, dropped int64) {
+	queryStats.l.Lock()
+	defer queryStats.l.Unlock()
+	for _, stat := range queryStats.list {
+		s := *stat
+		if s.Count > 0 {
+			s.Avg = s.Total / time.Duration(s.Count)
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Total > list[j].Total
+	})
+
+	return list, queryStats.dropped
+}
+
+//ResetQueryStats 清空sql统计
+func ResetQueryStats() {
+	queryStats.l.Lock()
+	defer queryStats.l.Unlock()
+	queryStats.list = make(map[string]*QueryStat)
+	queryStats.dropped = 0
+}
+
+//记录出错的sql，调用方的行号
+func logSQLError(err error, sql string, args []interface{}) {
+	logger.Output(4, "ERROR", err, sql, args)
+	recordQuery(sql, 0, true, false)
+}
+
+//QueryStatsHandler 查看sql统计的管理接口，默认注册在/db/stats
+//GET查看，按total倒序，可以用sort=count|errors|slow|total|avg|max、limit=10
+//POST清空
+func QueryStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		ResetQueryStats()
+		logger.Info("reset db query stats")
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+
+	list, dropped := GetQueryStats()
+	less := map[string]func(a, b QueryStat) bool{
+		"count":  func(a, b QueryStat) bool { return a.Count > b.Count },
+		"errors": func(a, b QueryStat) bool { return a.Errors > b.Errors },
+		"slow":   func(a, b QueryStat) bool { return a.Slow > b.Slow },
+		"avg":    func(a, b QueryStat) bool { return a.Avg > b.Avg },
+		"max":    func(a, b QueryStat) bool { return a.Max > b.Max },
+	}[r.FormValue("sort")]
+	if less != nil {
+		sort.SliceStable(list, func(i, j int) bool {
+			return less(list[i], list[j])
+		})
+	}
+	if limit, _ := strconv.Atoi(r.FormValue("limit")); limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b, err := encoding.JSON.Marshal(struct {
+		Stats   []QueryStat `json:"stats"`
+		Dropped int64       `json:"dropped"`
+	}{list, dropped})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	for sql, expect := range map[string]string{
+		"SELECT `id`, `name` FROM `user` WHERE (`age` > ? AND name = 'a''b') LIMIT 10": "SELECT id, name FROM user WHERE (age > ? AND name = ?) LIMIT ?",
+		"SELECT * FROM user_2019 WHERE id IN (?, ?, ?)":                                "SELECT * FROM user_2019 WHERE id IN (?+)",
+		"INSERT INTO \"user\" (\"a\",\"b\") VALUES ($1,$2),($3, $4)":                   "INSERT INTO user (a,b) VALUES (?,?)+",
+		"UPDATE user\n\tSET age = 1.5\n WHERE id in (1,2)":                             "UPDATE user SET age = ? WHERE id IN (?+)",
+	} {
+		if fp := Fingerprint(sql); fp != expect {
+			t.Errorf("%q fingerprint: %q, expect: %q", sql, fp, expect)
+		}
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	ResetQueryStats()
+	recordQuery("SELECT * FROM user WHERE id = 1", 10*time.Millisecond, false, false)
+	recordQuery("SELECT * FROM user WHERE id = 2", 30*time.Millisecond, false, true)
+	recordQuery("SELECT * FROM user WHERE id = 3", 0, true, false)
+	list, _ := GetQueryStats()
+	if len(list) != 1 {
+		t.Fatalf("stats: %+v", list)
+	}
+	s := list[0]
+	if s.Count != 2 || s.Errors != 1 || s.Slow != 1 || s.Avg != 20*time.Millisecond || s.Max != 30*time.Millisecond {
+		t.Fatalf("stat: %+v", s)
+	}
+	ResetQueryStats()
+	if list, _ = GetQueryStats(); len(list) != 0 {
+		t.Fatalf("reset: %+v", list)
+	}
+}
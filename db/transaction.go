@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-xorm/core"
+	logger "github.com/hsyan2008/go-logger"
+)
+
+type txState struct {
+	begun bool
+	//嵌套的层数，用于生成savepoint的名字
+	depth int
+}
+
+//Transaction 在事务里执行f，f返回nil的时候提交，返回错误或者panic的时候回滚，如
+//err := dao.Transaction(httpCtx.Ctx, func(tx *db.XormDao) error {
+//	user, _ := models.NewUser(tx)
+//	order, _ := models.NewOrder(tx)
+//	...
+//})
+//f里只能用tx执行sql，多个model用tx生成就在同一个事务里
+//已经在事务里的时候(包括Begin开始的事务)用savepoint，f出错只回滚到savepoint
+//不会修改dao本身，可以在全局的dao上使用
+func (d *XormDao) Transaction(ctx context.Context, f func(tx *XormDao) error) (err error) {
+	if d.sess != nil && d.tx != nil && d.tx.begun {
+		return d.nestedTransaction(f)
+	}
+
+	tx := *d
+	if ctx != nil {
+		tx.ctx = ctx
+	}
+	tx.NewSession()
+	defer tx.Close()
+	if err = tx.Begin(); err != nil {
+		return
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			_ = tx.Rollback()
+			panic(e)
+		}
+	}()
+
+	if err = f(&tx); err != nil {
+		if e := tx.Rollback(); e != nil {
+			logger.Error("rollback error:", e)
+		}
+		return
+	}
+
+	return tx.Commit()
+}
+
+//嵌套的时候使用外层事务的ctx
+func (d *XormDao) nestedTransaction(f func(tx *XormDao) error) (err error) {
+	d.tx.depth++
+	defer func() {
+		d.tx.depth--
+	}()
+	sp, err := newSavepoint(d.engine.Dialect().DBType(), fmt.Sprintf("sp_%d", d.tx.depth))
+	if err != nil {
+		return
+	}
+	if _, err = d.Exec(sp.save); err != nil {
+		return
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			_, _ = d.Exec(sp.rollback)
+			panic(e)
+		}
+	}()
+
+	if err = f(d); err != nil {
+		if _, e := d.Exec(sp.rollback); e != nil {
+			logger.Error("rollback to savepoint error:", e)
+		}
+		return
+	}
+
+	if len(sp.release) > 0 {
+		_, err = d.Exec(sp.release)
+	}
+
+	return
+}
+
+type savepoint struct {
+	save     string
+	rollback string
+	//为空表示不需要释放
+	release string
+}
+
+//各数据库savepoint的语法不同，mssql和oracle没有release
+func newSavepoint(dbType core.DbType, name string) (sp savepoint, err error) {
+	switch dbType {
+	case core.MYSQL, core.POSTGRES, core.SQLITE:
+		return savepoint{
+			save:     "SAVEPOINT " + name,
+			rollback: "ROLLBACK TO SAVEPOINT " + name,
+			release:  "RELEASE SAVEPOINT " + name,
+		}, nil
+	case core.MSSQL:
+		return savepoint{
+			save:     "SAVE TRANSACTION " + name,
+			rollback: "ROLLBACK TRANSACTION " + name,
+		}, nil
+	case core.ORACLE:
+		return savepoint{
+			save:     "SAVEPOINT " + name,
+			rollback: "ROLLBACK TO SAVEPOINT " + name,
+		}, nil
+	}
+
+	return sp, fmt.Errorf("nested transaction is not supported on %s", dbType)
+}
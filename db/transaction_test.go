@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-xorm/core"
+)
+
+func TestTransaction(t *testing.T) {
+	d := newSqliteDao(t, 0)
+	count := func(cond Cond) int64 {
+		n, err := d.Count(new(testUser), cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	err := d.Transaction(context.Background(), func(tx *XormDao) error {
+		if _, err := tx.Insert(&testUser{Name: "a"}); err != nil {
+			return err
+		}
+		//内层出错只回滚内层
+		err := tx.Transaction(nil, func(tx2 *XormDao) error {
+			if _, err := tx2.Insert(&testUser{Name: "b"}); err != nil {
+				return err
+			}
+			return errors.New("inner")
+		})
+		if err == nil || err.Error() != "inner" {
+			t.Fatalf("want inner error, got %v", err)
+		}
+		//内层提交，再嵌套一层回滚
+		return tx.Transaction(nil, func(tx2 *XormDao) error {
+			if _, err := tx2.Insert(&testUser{Name: "c"}); err != nil {
+				return err
+			}
+			_ = tx2.Transaction(nil, func(tx3 *XormDao) error {
+				_, _ = tx3.Insert(&testUser{Name: "d"})
+				return errors.New("inner")
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count(Cond{}) != 2 || count(Cond{"name in": []string{"a", "c"}}) != 2 {
+		t.Fatalf("want a and c committed, got %d rows", count(Cond{}))
+	}
+
+	//外层出错全部回滚
+	err = d.Transaction(nil, func(tx *XormDao) error {
+		_, _ = tx.Insert(&testUser{Name: "e"})
+		_ = tx.Transaction(nil, func(tx2 *XormDao) error {
+			_, err := tx2.Insert(&testUser{Name: "f"})
+			return err
+		})
+		return errors.New("outer")
+	})
+	if err == nil || count(Cond{}) != 2 {
+		t.Fatalf("outer error should rollback all, got %v %d rows", err, count(Cond{}))
+	}
+
+	//panic回滚并继续panic
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic should be rethrown")
+			}
+		}()
+		_ = d.Transaction(nil, func(tx *XormDao) error {
+			_, _ = tx.Insert(&testUser{Name: "g"})
+			panic("boom")
+		})
+	}()
+	if count(Cond{}) != 2 || d.sess != nil {
+		t.Fatalf("panic should rollback, got %d rows", count(Cond{}))
+	}
+
+	//Begin开始的事务里也可以嵌套
+	d.NewSession()
+	defer d.Close()
+	if err = d.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = d.Insert(&testUser{Name: "h"})
+	_ = d.Transaction(nil, func(tx *XormDao) error {
+		_, _ = tx.Insert(&testUser{Name: "i"})
+		return errors.New("inner")
+	})
+	if err = d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if count(Cond{}) != 3 || count(Cond{"name": "i"}) != 0 {
+		t.Fatalf("want h committed and i rolled back, got %d rows", count(Cond{}))
+	}
+}
+
+func TestNewSavepoint(t *testing.T) {
+	sp, err := newSavepoint(core.MSSQL, "sp_1")
+	if err != nil || sp.save != "SAVE TRANSACTION sp_1" || sp.rollback != "ROLLBACK TRANSACTION sp_1" || sp.release != "" {
+		t.Fatalf("mssql got %+v %v", sp, err)
+	}
+	sp, err = newSavepoint(core.POSTGRES, "sp_2")
+	if err != nil || sp.save != "SAVEPOINT sp_2" || sp.rollback != "ROLLBACK TO SAVEPOINT sp_2" || sp.release != "RELEASE SAVEPOINT sp_2" {
+		t.Fatalf("postgres got %+v %v", sp, err)
+	}
+	if _, err = newSavepoint("unknown", "sp_1"); err == nil {
+		t.Fatal("unknown dialect should fail")
+	}
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-xorm/xorm"
+)
+
+//乐观锁：model加上版本字段，如
+//Version int `json:"version" xorm:"not null default 1 INT(11) version"`
+//UpdateById、UpdateChanged会带上version = ?条件并把版本加1，数据已被修改的时候返回*ConflictError
+
+//ConflictError 乐观锁冲突，数据在加载后已被修改
+type ConflictError struct {
+	Table   string
+	Id      int64
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s id %d version %d conflict, the row has been modified", e.Table, e.Id, e.Version)
+}
+
+//IsConflict 是否是乐观锁冲突
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+//UpdateChanged 只更新t和old不同的列，old是修改前的数据，一般是加载后复制的，如
+//old := *user
+//user.Name = "new"
+//affected, err := dao.UpdateChanged(user, &old)
+//没有变化的时候不执行更新
+func (d *XormDao) UpdateChanged(t, old interface{}) (affected int64, err error) {
+	defer d.markWrite()
+	rv, ov := reflect.Indirect(reflect.ValueOf(t)), reflect.Indirect(reflect.ValueOf(old))
+	if rv.Kind() != reflect.Struct || rv.Type() != ov.Type() {
+		return 0, errors.New("UpdateChanged need two values of the same struct")
+	}
+	cols := d.changedCols(rv, ov)
+	if len(cols) == 0 {
+		return 0, nil
+	}
+
+	sess := d.sess
+	if sess == nil {
+		sess = d.newSession()
+		defer sess.Close()
+	}
+
+	id := rv.FieldByName("Id").Int()
+	version, ver := versionField(rv)
+	affected, err = sess.Id(id).Cols(cols...).Update(t)
+	if err != nil {
+		lastSQL, lastSQLArgs := sess.LastSQL()
+		logSQLError(err, lastSQL, lastSQLArgs)
+		return
+	}
+
+	return affected, d.checkConflict(sess, t, id, affected, version, ver)
+}
+
+//带版本字段的更新没有影响行数时，数据还存在就是冲突
+//xorm不管是否更新成功都会把t的版本加1，冲突时恢复为更新前的版本
+func (d *XormDao) checkConflict(sess *xorm.Session, t interface{}, id, affected int64, version reflect.Value, old int64) error {
+	if affected > 0 || !version.IsValid() {
+		return nil
+	}
+	has, err := sess.Table(t).Where(d.engine.Dialect().Quote("id")+" = ?", id).Exist()
+	if err != nil || !has {
+		return err
+	}
+	setInt(version, old)
+
+	return &ConflictError{Table: d.engine.TableName(t), Id: id, Version: old}
+}
+
+//比较每个字段，返回不同的列名，跳过主键、版本和自动时间的列
+func (d *XormDao) changedCols(rv, ov reflect.Value) (cols []string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		tags := strings.Fields(f.Tag.Get("xorm"))
+		if hasTag(tags, "-") || hasTag(tags, "pk") || hasTag(tags, "version") ||
+			hasTag(tags, "created") || hasTag(tags, "updated") || hasTag(tags, "<-") {
+			continue
+		}
+		if f.Anonymous || hasTag(tags, "extends") {
+			if rv.Field(i).Kind() == reflect.Struct {
+				cols = append(cols, d.changedCols(rv.Field(i), ov.Field(i))...)
+			}
+			continue
+		}
+		if f.Name == "Id" || reflect.DeepEqual(rv.Field(i).Interface(), ov.Field(i).Interface()) {
+			continue
+		}
+		name := d.engine.GetColumnMapper().Obj2Table(f.Name)
+		for _, tag := range tags {
+			if len(tag) > 2 && tag[0] == '\'' && tag[len(tag)-1] == '\'' {
+				name = tag[1 : len(tag)-1]
+			}
+		}
+		cols = append(cols, name)
+	}
+
+	return
+}
+
+//版本字段和它的值，包括嵌入的struct
+func versionField(rv reflect.Value) (field reflect.Value, version int64) {
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tags := strings.Fields(f.Tag.Get("xorm"))
+		if hasTag(tags, "version") {
+			switch rv.Field(i).Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return rv.Field(i), rv.Field(i).Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return rv.Field(i), int64(rv.Field(i).Uint())
+			}
+		}
+		if f.Anonymous || hasTag(tags, "extends") {
+			if field, version = versionField(rv.Field(i)); field.IsValid() {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func setInt(v reflect.Value, i int64) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(i))
+	default:
+		v.SetInt(i)
+	}
+}
+
+func hasTag(tags []string, name string) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestUpdateChanged(t *testing.T) {
+	d := newSqliteDao(t, 1)
+	var u testUser
+	if has, err := d.SearchOne(&u, Cond{"id": 1}); !has || err != nil {
+		t.Fatal(has, err)
+	}
+	//其他地方改了age，只更新name的时候不能覆盖
+	if _, err := d.Exec("UPDATE test_user SET age = 99 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	old := u
+	u.Name = "new"
+	if n, err := d.UpdateChanged(&u, &old); n != 1 || err != nil {
+		t.Fatalf("UpdateChanged want 1, got %d %v", n, err)
+	}
+	var got testUser
+	_, _ = d.SearchOne(&got, Cond{"id": 1})
+	if got.Name != "new" || got.Age != 99 {
+		t.Fatalf("only changed columns should be updated, got %+v", got)
+	}
+
+	if n, err := d.UpdateChanged(&u, &u); n != 0 || err != nil {
+		t.Fatalf("nothing changed want 0, got %d %v", n, err)
+	}
+	if _, err := d.UpdateChanged(&u, &testItem{}); err == nil {
+		t.Fatal("different types should fail")
+	}
+}
+
+func TestUpdateConflict(t *testing.T) {
+	d := newSqliteDao(t, 0)
+	item := &testItem{Name: "a", Price: 1}
+	if _, err := d.Insert(item); err != nil {
+		t.Fatal(err)
+	}
+	var a, b testItem
+	_, _ = d.SearchOne(&a, Cond{"id": item.Id})
+	_, _ = d.SearchOne(&b, Cond{"id": item.Id})
+
+	old := a
+	a.Price = 5
+	if n, err := d.UpdateChanged(&a, &old); n != 1 || err != nil || a.Version != old.Version+1 {
+		t.Fatalf("UpdateChanged want 1 and version %d, got %d %v %d", old.Version+1, n, err, a.Version)
+	}
+
+	//b是旧版本
+	oldB := b
+	b.Name = "b"
+	n, err := d.UpdateChanged(&b, &oldB)
+	if n != 0 || !IsConflict(err) || b.Version != oldB.Version {
+		t.Fatalf("stale UpdateChanged want conflict and version %d, got %d %v %d", oldB.Version, n, err, b.Version)
+	}
+	if e := err.(*ConflictError); e.Id != int64(item.Id) || e.Version != int64(oldB.Version) || e.Table != "test_item" {
+		t.Fatalf("ConflictError got %+v", e)
+	}
+	if _, err = d.UpdateById(&b); !IsConflict(err) || b.Version != oldB.Version {
+		t.Fatalf("stale UpdateById want conflict, got %v %d", err, b.Version)
+	}
+
+	var got testItem
+	_, _ = d.SearchOne(&got, Cond{"id": item.Id})
+	if got.Name != "a" || got.Price != 5 || got.Version != a.Version {
+		t.Fatalf("conflicted update should not be applied, got %+v", got)
+	}
+
+	//重新加载后可以更新
+	got.Name = "c"
+	if n, err = d.UpdateById(&got); n != 1 || err != nil {
+		t.Fatalf("UpdateById want 1, got %d %v", n, err)
+	}
+	//不存在的不是冲突
+	if n, err = d.UpdateById(&testItem{Models: Models{Id: 999}, Version: 1}); n != 0 || err != nil {
+		t.Fatalf("missing row want 0 and no error, got %d %v", n, err)
+	}
+}
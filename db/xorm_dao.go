@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/go-xorm/xorm"
-	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw2/common"
 	"github.com/hsyan2008/hfw2/configs"
 )
@@ -22,7 +22,7 @@ func NewXormDao(config configs.AllConfig, dbConfig configs.DbConfig) (instance *
 		return nil, errors.New("nil db config")
 	}
 
-	instance = &XormDao{}
+	instance = &XormDao{mark: new(writeMark)}
 
 	instance.engine, err = InitDb(config, dbConfig)
 	if err != nil {
@@ -37,32 +37,51 @@ func NewXormDao(config configs.AllConfig, dbConfig configs.DbConfig) (instance *
 	return instance, err
 }
 
+//NewXormDaoWithEngine 用已有的engine生成dao，如InitDb返回的engine
+func NewXormDaoWithEngine(engine xorm.EngineInterface) *XormDao {
+	return &XormDao{engine: engine, mark: new(writeMark)}
+}
+
 type XormDao struct {
 	engine  xorm.EngineInterface
 	isCache bool
 	cacher  *xorm.LRUCacher
 	sess    *xorm.Session
+	//不自动过滤软删除的数据
+	unscoped bool
+	ctx      context.Context
+	//和sess一起创建，记录事务状态
+	tx *txState
+	//有从库的时候读也走主库
+	master bool
+	//ctx没有WithReadYourWrites的时候，写的时间记录在这里，WithContext和Master的副本共用
+	mark *writeMark
 }
 
+//UpdateById 按id更新所有列，有版本字段的时候数据已被修改返回*ConflictError
 func (d *XormDao) UpdateById(t interface{}) (affected int64, err error) {
+	defer d.markWrite()
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
 	id := reflect.ValueOf(t).Elem().FieldByName("Id").Int()
+	version, old := versionField(reflect.ValueOf(t).Elem())
 	affected, err = sess.Id(id).AllCols().Update(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
+		return
 	}
 
-	return
+	return affected, d.checkConflict(sess, t, id, affected, version, old)
 }
 
 func (d *XormDao) UpdateByIds(t interface{}, params Cond,
 	ids []interface{}) (affected int64, err error) {
+	defer d.markWrite()
 
 	if len(ids) == 0 {
 		return 0, errors.New("ids parameters error")
@@ -70,14 +89,14 @@ func (d *XormDao) UpdateByIds(t interface{}, params Cond,
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
 	affected, err = sess.Table(t).In("id", ids).Update(params)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -85,16 +104,17 @@ func (d *XormDao) UpdateByIds(t interface{}, params Cond,
 
 func (d *XormDao) UpdateByWhere(t interface{}, params Cond,
 	where Cond) (affected int64, err error) {
+	defer d.markWrite()
 	if len(where) == 0 {
 		return 0, errors.New("where paramters error")
 	}
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, where, false, false)
+	sess, err = d.buildCond(sess, t, where, false, false)
 	if err != nil {
 		return
 	}
@@ -102,37 +122,39 @@ func (d *XormDao) UpdateByWhere(t interface{}, params Cond,
 	affected, err = sess.Table(t).Update(params)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 	return
 }
 
 func (d *XormDao) Insert(t interface{}) (affected int64, err error) {
+	defer d.markWrite()
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
 	affected, err = sess.Insert(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 	return
 }
 
 func (d *XormDao) InsertMulti(t interface{}) (affected int64, err error) {
+	defer d.markWrite()
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
 	affected, err = sess.InsertMulti(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 	return
 }
@@ -140,10 +162,10 @@ func (d *XormDao) InsertMulti(t interface{}) (affected int64, err error) {
 func (d *XormDao) SearchOne(t interface{}, cond Cond) (has bool, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, cond, true, false)
+	sess, err = d.buildCond(sess, t, cond, true, false)
 	if err != nil {
 		return
 	}
@@ -151,77 +173,54 @@ func (d *XormDao) SearchOne(t interface{}, cond Cond) (has bool, err error) {
 	has, err = sess.Get(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 	return
 }
 
-func (d *XormDao) buildCond(sess *xorm.Session, cond Cond, isOrder, isPaging bool) (session *xorm.Session, err error) {
+func (d *XormDao) buildCond(sess *xorm.Session, t interface{}, cond Cond, isOrder, isPaging bool) (session *xorm.Session, err error) {
 	var (
-		str      []string
 		orderby  = "id desc"
 		page     = 1
 		pageSize = DefaultPageSize
 		where    string
-		args     []interface{}
+		rest     = make(Cond, len(cond))
 	)
-FOR:
 	for k, v := range cond {
-		k = strings.ToLower(k)
-		switch k {
+		switch strings.ToLower(k) {
 		case "orderby":
 			if isOrder {
 				orderby = v.(string)
 			}
-			continue FOR
 		case "page":
 			if isPaging {
 				page = common.Max(v.(int), page)
 			}
-			continue FOR
 		case "pagesize":
 			if isPaging {
 				if v.(int) > 0 {
 					pageSize = v.(int)
 				}
 			}
-			continue FOR
 		case "where":
 			where = v.(string)
-			continue FOR
 		case "sql":
 			sess.SQL(v)
-			continue FOR
 		case "select":
 			sess.Select(v.(string))
-			continue FOR
 		case "distinct":
 			sess.Distinct(v.(string))
-			continue FOR
-		}
-
-		keys := strings.Fields(k)
-		switch len(keys) {
-		case 1:
-			str = append(str, fmt.Sprintf("`%s` = ?", k))
-			args = append(args, v)
-			continue FOR
-		case 2:
-			switch keys[1] {
-			case "in":
-				sess.In(keys[0], v)
-			default:
-				return nil, errors.New("error cond key")
-			}
-			continue FOR
 		default:
-			return nil, errors.New("error cond")
+			rest[k] = v
 		}
 	}
 
-	var strs string
-	if len(str) > 0 {
-		strs = strings.Join(str, " AND ")
+	if d.isSoftDeleteScoped(t, rest) {
+		rest[softDeleteColumn] = 0
+	}
+	strs, args, err := buildWhere(d.engine.Dialect().Quote, rest)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(where) > 0 && len(strs) > 0 {
@@ -244,10 +243,10 @@ FOR:
 func (d *XormDao) Search(t interface{}, cond Cond) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, cond, true, true)
+	sess, err = d.buildCond(sess, t, cond, true, true)
 	if err != nil {
 		return
 	}
@@ -255,7 +254,7 @@ func (d *XormDao) Search(t interface{}, cond Cond) (err error) {
 	err = sess.Find(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -264,10 +263,10 @@ func (d *XormDao) Search(t interface{}, cond Cond) (err error) {
 func (d *XormDao) Rows(t interface{}, cond Cond) (rows *xorm.Rows, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, cond, true, true)
+	sess, err = d.buildCond(sess, t, cond, true, true)
 	if err != nil {
 		return
 	}
@@ -275,7 +274,7 @@ func (d *XormDao) Rows(t interface{}, cond Cond) (rows *xorm.Rows, err error) {
 	rows, err = sess.Rows(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -284,10 +283,10 @@ func (d *XormDao) Rows(t interface{}, cond Cond) (rows *xorm.Rows, err error) {
 func (d *XormDao) Iterate(t interface{}, cond Cond, f xorm.IterFunc) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, cond, true, true)
+	sess, err = d.buildCond(sess, t, cond, true, true)
 	if err != nil {
 		return
 	}
@@ -295,7 +294,7 @@ func (d *XormDao) Iterate(t interface{}, cond Cond, f xorm.IterFunc) (err error)
 	err = sess.Iterate(t, f)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -304,14 +303,18 @@ func (d *XormDao) Iterate(t interface{}, cond Cond, f xorm.IterFunc) (err error)
 func (d *XormDao) GetMulti(t interface{}, ids ...interface{}) (err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 
-	err = sess.In("id", ids...).Find(t)
+	sess.In("id", ids...)
+	if d.isSoftDeleteScoped(t, nil) {
+		sess.And(d.engine.Dialect().Quote(softDeleteColumn)+" = ?", 0)
+	}
+	err = sess.Find(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -320,10 +323,10 @@ func (d *XormDao) GetMulti(t interface{}, ids ...interface{}) (err error) {
 func (d *XormDao) Count(t interface{}, cond Cond) (total int64, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
-	sess, err = d.buildCond(sess, cond, false, false)
+	sess, err = d.buildCond(sess, t, cond, false, false)
 	if err != nil {
 		return
 	}
@@ -331,12 +334,18 @@ func (d *XormDao) Count(t interface{}, cond Cond) (total int64, err error) {
 	total, err = sess.Count(t)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
 }
 
+//Quote 按数据库类型给表名、列名加引号，mysql是`name`，postgres和sqlite是"name"
+func (d *XormDao) Quote(name string) string {
+	return d.engine.Dialect().Quote(name)
+}
+
+//Replace sql是REPLACE `table` SET 这样的前缀，只支持mysql
 func (d *XormDao) Replace(sql string, cond Cond) (id int64, err error) {
 	var (
 		str  []string
@@ -347,7 +356,10 @@ func (d *XormDao) Replace(sql string, cond Cond) (id int64, err error) {
 		if k == "orderby" || k == "page" || k == "pagesize" || k == "where" {
 			continue
 		}
-		str = append(str, fmt.Sprintf("`%s` = ?", k))
+		if !columnRegexp.MatchString(k) {
+			return 0, fmt.Errorf("invalid column name %q", k)
+		}
+		str = append(str, quoteColumn(d.Quote, k)+" = ?")
 		args = append(args, v)
 	}
 
@@ -361,19 +373,20 @@ func (d *XormDao) Replace(sql string, cond Cond) (id int64, err error) {
 
 //调用方必须确保执行Exec后，再执行ClearCache
 func (d *XormDao) Exec(sqlStr string, args ...interface{}) (rs sql.Result, err error) {
+	defer d.markWrite()
 	tmp := make([]interface{}, 0)
 	tmp = append(tmp, sqlStr)
 	tmp = append(tmp, args...)
 
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	rs, err = sess.Exec(tmp...)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -382,13 +395,13 @@ func (d *XormDao) Exec(sqlStr string, args ...interface{}) (rs sql.Result, err e
 func (d *XormDao) Query(args ...interface{}) (rs []map[string][]byte, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	rs, err = sess.Query(args...)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -397,13 +410,13 @@ func (d *XormDao) Query(args ...interface{}) (rs []map[string][]byte, err error)
 func (d *XormDao) QueryString(args ...interface{}) (rs []map[string]string, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	rs, err = sess.QueryString(args...)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -412,13 +425,13 @@ func (d *XormDao) QueryString(args ...interface{}) (rs []map[string]string, err
 func (d *XormDao) QueryInterface(args ...interface{}) (rs []map[string]interface{}, err error) {
 	sess := d.sess
 	if sess == nil {
-		sess = d.engine.NewSession()
+		sess = d.newSession()
 		defer sess.Close()
 	}
 	rs, err = sess.QueryInterface(args...)
 	if err != nil {
 		lastSQL, lastSQLArgs := sess.LastSQL()
-		logger.Error(err, lastSQL, lastSQLArgs)
+		logSQLError(err, lastSQL, lastSQLArgs)
 	}
 
 	return
@@ -449,13 +462,15 @@ func (d *XormDao) ClearCache(t interface{}) {
 //然后Begin，如果不Commit，会自动在Close里Rollback掉
 //Notice: 注意并发不安全，请勿在全局上使用
 func (d *XormDao) NewSession() {
-	d.sess = d.engine.NewSession()
+	d.sess = d.newSession()
+	d.tx = &txState{}
 }
 
 func (d *XormDao) Close() {
 	if d.sess != nil {
 		d.sess.Close()
 		d.sess = nil
+		d.tx = nil
 	}
 }
 
@@ -464,7 +479,12 @@ func (d *XormDao) Begin() error {
 		return errors.New("please NewSession at first")
 	}
 
-	return d.sess.Begin()
+	err := d.sess.Begin()
+	if err == nil {
+		d.tx.begun = true
+	}
+
+	return err
 }
 
 func (d *XormDao) Rollback() error {
@@ -472,13 +492,18 @@ func (d *XormDao) Rollback() error {
 		return errors.New("please NewSession at first")
 	}
 
+	d.tx.begun = false
+
 	return d.sess.Rollback()
 }
 
 func (d *XormDao) Commit() error {
+	defer d.markWrite()
 	if d.sess == nil {
 		return errors.New("please NewSession at first")
 	}
 
+	d.tx.begun = false
+
 	return d.sess.Commit()
 }
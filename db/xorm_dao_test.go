@@ -0,0 +1,200 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+type testUser struct {
+	Models `xorm:"extends"`
+	Name   string
+	Age    int
+}
+
+func (testUser) TableName() string {
+	return "test_user"
+}
+
+//带乐观锁的
+type testItem struct {
+	Models  `xorm:"extends"`
+	Name    string
+	Price   int
+	Version int `xorm:"not null default 1 INT(11) version"`
+}
+
+func (testItem) TableName() string {
+	return "test_item"
+}
+
+//每个测试用单独的sqlite文件，插入n个用户，age从1到n
+func newSqliteDao(t *testing.T, n int) *XormDao {
+	config := configs.DbStdConfig{Driver: "sqlite", Dbname: filepath.Join(t.TempDir(), "test.db")}
+	engine, _, err := getEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		engineMap.Delete(common.Md5(getDbDsn(config)))
+		_ = engine.Close()
+	})
+	if err = engine.Sync2(new(testUser), new(testItem)); err != nil {
+		t.Fatal(err)
+	}
+	d := NewXormDaoWithEngine(engine)
+	for i := 1; i <= n; i++ {
+		if _, err = d.Insert(&testUser{Name: "user", Age: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return d
+}
+
+func TestXormDaoInsert(t *testing.T) {
+	d := newSqliteDao(t, 0)
+
+	u := &testUser{Name: "a", Age: 1}
+	affected, err := d.Insert(u)
+	if err != nil || affected != 1 || u.Id == 0 {
+		t.Fatalf("Insert affected: %d, id: %d, err: %v", affected, u.Id, err)
+	}
+	affected, err = d.InsertMulti([]*testUser{{Name: "b", Age: 2}, {Name: "c", Age: 3}})
+	if err != nil || affected != 2 {
+		t.Fatalf("InsertMulti affected: %d, err: %v", affected, err)
+	}
+	total, err := d.Count(new(testUser), Cond{})
+	if err != nil || total != 3 {
+		t.Fatalf("Count: %d, err: %v", total, err)
+	}
+}
+
+func TestXormDaoSearch(t *testing.T) {
+	d := newSqliteDao(t, 5)
+
+	var users []testUser
+	err := d.Search(&users, Cond{"age >": 2, "orderby": "age asc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].Age != 3 || users[2].Age != 5 {
+		t.Fatalf("Search got %+v", users)
+	}
+
+	//默认按id倒序
+	users = nil
+	if err = d.Search(&users, Cond{"page": 2, "pagesize": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Age != 3 || users[1].Age != 2 {
+		t.Fatalf("Search page 2 got %+v", users)
+	}
+
+	u := new(testUser)
+	has, err := d.SearchOne(u, Cond{"or": []Cond{{"age": 1}, {"age": 4}}})
+	if err != nil || !has || u.Age != 4 {
+		t.Fatalf("SearchOne has: %v, age: %d, err: %v", has, u.Age, err)
+	}
+	has, err = d.SearchOne(new(testUser), Cond{"age": 100})
+	if err != nil || has {
+		t.Fatalf("SearchOne not exists has: %v, err: %v", has, err)
+	}
+
+	users = nil
+	if err = d.GetMulti(&users, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("GetMulti got %+v", users)
+	}
+
+	total, err := d.Count(new(testUser), Cond{"age between": []int{2, 4}})
+	if err != nil || total != 3 {
+		t.Fatalf("Count: %d, err: %v", total, err)
+	}
+}
+
+func TestXormDaoUpdate(t *testing.T) {
+	d := newSqliteDao(t, 5)
+	get := func(id int) *testUser {
+		u := new(testUser)
+		if has, err := d.SearchOne(u, Cond{"id": id}); err != nil || !has {
+			t.Fatalf("SearchOne id: %d, has: %v, err: %v", id, has, err)
+		}
+		return u
+	}
+
+	u := get(1)
+	u.Name = "a"
+	affected, err := d.UpdateById(u)
+	if err != nil || affected != 1 {
+		t.Fatalf("UpdateById affected: %d, err: %v", affected, err)
+	}
+	if u = get(1); u.Name != "a" || u.Age != 1 {
+		t.Fatalf("UpdateById got %+v", u)
+	}
+
+	affected, err = d.UpdateByIds(new(testUser), Cond{"name": "b"}, []interface{}{2, 3})
+	if err != nil || affected != 2 {
+		t.Fatalf("UpdateByIds affected: %d, err: %v", affected, err)
+	}
+	if _, err = d.UpdateByIds(new(testUser), Cond{"name": "b"}, nil); err == nil {
+		t.Fatal("UpdateByIds without ids should fail")
+	}
+
+	affected, err = d.UpdateByWhere(new(testUser), Cond{"name": "c"}, Cond{"age >=": 4})
+	if err != nil || affected != 2 {
+		t.Fatalf("UpdateByWhere affected: %d, err: %v", affected, err)
+	}
+	if _, err = d.UpdateByWhere(new(testUser), Cond{"name": "c"}, Cond{}); err == nil {
+		t.Fatal("UpdateByWhere without where should fail")
+	}
+
+	for name, want := range map[string]int64{"a": 1, "b": 2, "c": 2} {
+		if total, _ := d.Count(new(testUser), Cond{"name": name}); total != want {
+			t.Errorf("name %s count: %d, want %d", name, total, want)
+		}
+	}
+}
+
+func TestXormDaoSession(t *testing.T) {
+	d := newSqliteDao(t, 0)
+	if err := d.Begin(); err == nil {
+		t.Fatal("Begin without NewSession should fail")
+	}
+
+	d.NewSession()
+	if err := d.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Insert(&testUser{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	d.NewSession()
+	if err := d.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Insert(&testUser{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	var users []testUser
+	if err := d.Search(&users, Cond{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "b" {
+		t.Fatalf("want only b committed, got %+v", users)
+	}
+}
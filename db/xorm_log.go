@@ -2,6 +2,9 @@ package db
 
 import (
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/go-xorm/core"
 	logger "github.com/hsyan2008/go-logger"
@@ -9,6 +12,12 @@ import (
 
 type xormLog struct {
 	isShowSQL bool
+	//不打印普通sql
+	hideSQL bool
+	//慢查询阈值，0不记录
+	slowQuery time.Duration
+	//慢查询单独的日志，nil的时候用logger
+	slowLog *log.Logger
 }
 
 func (this *xormLog) Debug(v ...interface{}) {
@@ -23,7 +32,28 @@ func (this *xormLog) Info(v ...interface{}) {
 	logger.Output(4, "INFO", v...)
 }
 
+//xorm执行sql后用Infof打印，格式是"[SQL] %s %#v - took: %v"或者"[SQL] %s - took: %v"
 func (this *xormLog) Infof(format string, v ...interface{}) {
+	if strings.HasPrefix(format, "[SQL]") && len(v) >= 2 {
+		sql, _ := v[0].(string)
+		d, ok := v[len(v)-1].(time.Duration)
+		if ok {
+			isSlow := this.slowQuery > 0 && d >= this.slowQuery
+			recordQuery(sql, d, false, isSlow)
+			if isSlow {
+				msg := "[SLOW SQL]" + fmt.Sprintf(format, v...)[len("[SQL]"):]
+				if this.slowLog != nil {
+					_ = this.slowLog.Output(2, msg)
+				} else {
+					logger.Output(4, "WARN", msg)
+				}
+				return
+			}
+		}
+		if this.hideSQL {
+			return
+		}
+	}
 	logger.Output(4, "INFO", fmt.Sprintf(format, v...))
 }
 
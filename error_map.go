@@ -1,21 +1,102 @@
 package hfw
 
-var errorMap = map[int64]string{
-	400: "request error",
-	500: "system error",
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+//配置重载的时候会修改，需要加锁
+//list是base加上配置里的ErrorMap，配置里的优先
+var errorMap = struct {
+	l *sync.RWMutex
+	//默认的和代码里SetErrorMap、AddErrorMap设置的
+	base map[int64]string
+	//配置里的ErrorMap
+	config map[int64]string
+	list   map[int64]string
+}{
+	l: &sync.RWMutex{},
+	base: map[int64]string{
+		400: "request error",
+		500: "system error",
+	},
+	list: map[int64]string{
+		400: "request error",
+		500: "system error",
+	},
+}
+
+//需要加锁后调用
+func rebuildErrorMap() {
+	list := make(map[int64]string, len(errorMap.base)+len(errorMap.config))
+	for k, v := range errorMap.base {
+		list[k] = v
+	}
+	for k, v := range errorMap.config {
+		list[k] = v
+	}
+	errorMap.list = list
 }
 
 func SetErrorMap(m map[int64]string) {
-	errorMap = m
+	base := make(map[int64]string, len(m))
 	for k, v := range m {
-		errorMap[k] = v
+		base[k] = v
 	}
+	errorMap.l.Lock()
+	errorMap.base = base
+	rebuildErrorMap()
+	errorMap.l.Unlock()
 }
 
 func AddErrorMap(errNo int64, errMsg string) {
-	errorMap[errNo] = errMsg
+	errorMap.l.Lock()
+	errorMap.base[errNo] = errMsg
+	if _, ok := errorMap.config[errNo]; !ok {
+		errorMap.list[errNo] = errMsg
+	}
+	errorMap.l.Unlock()
 }
 
 func GetErrorMap(errNo int64) string {
-	return errorMap[errNo]
+	errorMap.l.RLock()
+	defer errorMap.l.RUnlock()
+	return errorMap.list[errNo]
+}
+
+func parseConfigErrorMap(m map[string]string) (map[int64]string, error) {
+	tmp := make(map[int64]string, len(m))
+	for k, v := range m {
+		errNo, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error ErrorMap key: %s", k)
+		}
+		tmp[errNo] = v
+	}
+
+	return tmp, nil
+}
+
+//检查配置里的ErrorMap，不修改
+func checkErrorMap(m map[string]string) error {
+	_, err := parseConfigErrorMap(m)
+	return err
+}
+
+//配置里的ErrorMap，key是字符串格式的错误码
+//全部解析成功才修改，有错误的时候不做任何修改
+//替换上次配置里的，重载后配置里删掉的错误码也会删掉或者恢复成默认的
+func loadConfigErrorMap(m map[string]string) error {
+	tmp, err := parseConfigErrorMap(m)
+	if err != nil {
+		return err
+	}
+
+	errorMap.l.Lock()
+	defer errorMap.l.Unlock()
+	errorMap.config = tmp
+	rebuildErrorMap()
+
+	return nil
 }
@@ -0,0 +1,30 @@
+package hfw
+
+import "testing"
+
+func TestLoadConfigErrorMap(t *testing.T) {
+	defer func() {
+		_ = loadConfigErrorMap(nil)
+	}()
+	AddErrorMap(1001, "user not found")
+	defer SetErrorMap(map[int64]string{400: "request error", 500: "system error"})
+
+	if err := loadConfigErrorMap(map[string]string{"500": "busy", "1002": "no money"}); err != nil {
+		t.Fatal(err)
+	}
+	if GetErrorMap(500) != "busy" || GetErrorMap(1002) != "no money" || GetErrorMap(1001) != "user not found" {
+		t.Fatal("config ErrorMap should be merged with default")
+	}
+
+	//重载后删掉的错误码不再生效，覆盖的恢复默认
+	if err := loadConfigErrorMap(map[string]string{"1003": "closed"}); err != nil {
+		t.Fatal(err)
+	}
+	if GetErrorMap(500) != "system error" || GetErrorMap(1002) != "" || GetErrorMap(1003) != "closed" {
+		t.Fatalf("want 500 restored and 1002 removed, got %q %q", GetErrorMap(500), GetErrorMap(1002))
+	}
+
+	if err := loadConfigErrorMap(map[string]string{"abc": "x"}); err == nil || GetErrorMap(1003) != "closed" {
+		t.Fatal("invalid ErrorMap should not change anything")
+	}
+}
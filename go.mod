@@ -1,19 +1,27 @@
 module github.com/hsyan2008/hfw2
 
+go 1.16
+
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
+	github.com/denisenkom/go-mssqldb v0.0.0-20181014144952-4e0d7dc8888f
 	github.com/fsnotify/fsnotify v1.4.7 // indirect
+	github.com/go-sql-driver/mysql v1.4.0
+	github.com/go-xorm/core v0.6.0
+	github.com/go-xorm/xorm v0.7.1
 	github.com/google/gops v0.3.6 // indirect
 	github.com/google/uuid v1.1.0
 	github.com/gorilla/websocket v1.4.0 // indirect
 	github.com/hsyan2008/go-logger v0.0.0-20190102044303-0c1f8d9bfaf1
 	github.com/hsyan2008/gracehttp v0.0.0-20181020095239-2f290fb99640
 	github.com/json-iterator/go v1.1.5
+	github.com/lib/pq v1.0.0
 	github.com/mediocregopher/radix.v2 v0.0.0-20181115013041-b67df6e626f9
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
 	github.com/robfig/cron v0.0.0-20180505203441-b41be1df6967 // indirect
 	github.com/shirou/gopsutil v2.18.12+incompatible // indirect
 	google.golang.org/grpc v1.18.0
+	modernc.org/sqlite v1.14.5
 )
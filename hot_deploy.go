@@ -1,6 +1,8 @@
 package hfw
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,14 +17,25 @@ import (
 	"github.com/shirou/gopsutil/process"
 )
 
+//DefaultHotDeployDebounce 最后一次变化后等待的默认时间
+const DefaultHotDeployDebounce = time.Second
+
 func HotDeploy(hotDeployConfig configs.HotDeployConfig) {
+	signalContext.WgAdd()
+	defer signalContext.WgDone()
+
+	hotDeploy(signalContext.Ctx, hotDeployConfig)
+}
+
+func hotDeploy(ctx context.Context, hotDeployConfig configs.HotDeployConfig) {
 
 	if hotDeployConfig.Dep <= 0 || hotDeployConfig.Dep > 10 {
 		hotDeployConfig.Dep = 5
 	}
-
-	signalContext.WgAdd()
-	defer signalContext.WgDone()
+	debounce := DefaultHotDeployDebounce
+	if hotDeployConfig.Debounce > 0 {
+		debounce = time.Duration(hotDeployConfig.Debounce) * time.Millisecond
+	}
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -43,10 +56,12 @@ func HotDeploy(hotDeployConfig configs.HotDeployConfig) {
 		return
 	}
 
-	var isToRestart bool
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
 	for {
 		select {
-		case <-signalContext.Ctx.Done():
+		case <-ctx.Done():
 			return
 		case event, ok := <-watcher.Events:
 			if !ok {
@@ -54,9 +69,19 @@ func HotDeploy(hotDeployConfig configs.HotDeployConfig) {
 			}
 
 			baseName := filepath.Base(event.Name)
-			if baseName[:1] == "." {
+			if baseName[:1] == "." || isHotDeployIgnored(hotDeployConfig, event.Name) {
 				continue
 			}
+
+			//新建的目录也要监听
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := addWatch(watcher, hotDeployConfig, event.Name, getWatchDep(event.Name)); err != nil {
+						logger.Warn("add watch failed:", err)
+					}
+				}
+			}
+
 			if len(hotDeployConfig.Exts) > 0 {
 				ext := filepath.Ext(event.Name)
 				if len(ext) > 0 {
@@ -74,16 +99,20 @@ func HotDeploy(hotDeployConfig configs.HotDeployConfig) {
 				}
 			}
 
-			isToRestart = true
+			timer.Reset(debounce)
 
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
 			}
 			logger.Warn("error:", err)
-		case <-time.After(time.Second):
-			if !isToRestart {
-				continue
+		case <-timer.C:
+			if len(hotDeployConfig.BuildCmd) > 0 {
+				if err = hotDeployBuild(ctx, hotDeployConfig.BuildCmd); err != nil {
+					//编译失败保留当前进程，等待下次修改
+					logger.Error(err)
+					continue
+				}
 			}
 			if common.IsGoRun() {
 				err = p.Signal(syscall.SIGINT)
@@ -130,14 +159,70 @@ func HotDeploy(hotDeployConfig configs.HotDeployConfig) {
 	}
 }
 
+//在项目目录下用sh执行编译命令，失败的时候编译输出打印到标准错误
+func hotDeployBuild(ctx context.Context, buildCmd string) error {
+	logger.Info("hot deploy build:", buildCmd)
+	cmd := exec.CommandContext(ctx, "sh", "-c", buildCmd)
+	cmd.Dir = APPPATH
+	cmd.Env = os.Environ()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", out)
+		return fmt.Errorf("hot deploy build failed, keep current process: %v", err)
+	}
+
+	return nil
+}
+
+//相对项目路径的目录深度
+func getWatchDep(path string) int {
+	rel, err := filepath.Rel(APPPATH, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+//匹配相对项目路径的路径、路径的前缀目录或者文件名
+func isHotDeployIgnored(hotDeployConfig configs.HotDeployConfig, path string) bool {
+	if len(hotDeployConfig.Ignores) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(APPPATH, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	base := filepath.Base(path)
+	for _, p := range hotDeployConfig.Ignores {
+		p = strings.TrimSuffix(filepath.ToSlash(p), "/")
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+		if strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func addWatch(watcher *fsnotify.Watcher, hotDeployConfig configs.HotDeployConfig, path string, dep int) (err error) {
 	if dep > hotDeployConfig.Dep {
 		return
 	}
+	if dep > 0 && isHotDeployIgnored(hotDeployConfig, path) {
+		return
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return
 	}
+	defer f.Close()
 	fi, err := f.Stat()
 	if err != nil {
 		return
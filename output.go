@@ -113,7 +113,7 @@ func (httpCtx *HTTPContext) render() (t *template.Template) {
 		render = httpCtx.renderFile
 	}
 
-	if Config.Template.IsCache {
+	if GetConfig().Template.IsCache {
 		templatesCache.l.RLock()
 		if t, ok = templatesCache.list[key]; !ok {
 			templatesCache.l.RUnlock()
@@ -139,8 +139,8 @@ func (httpCtx *HTTPContext) renderHTML() (t *template.Template) {
 	} else {
 		t = template.Must(template.New(httpCtx.Path).Funcs(httpCtx.FuncMap).Parse(httpCtx.Template))
 	}
-	if len(Config.Template.WidgetsPath) > 0 {
-		t = template.Must(t.ParseGlob(Config.Template.WidgetsPath))
+	if widgets := GetConfig().Template.WidgetsPath; len(widgets) > 0 {
+		t = template.Must(t.ParseGlob(widgets))
 	}
 
 	return
@@ -150,7 +150,7 @@ func (httpCtx *HTTPContext) renderFile() (t *template.Template) {
 	if common.IsExist(httpCtx.TemplateFile) {
 		templateFilePath = httpCtx.TemplateFile
 	} else {
-		templateFilePath = filepath.Join(Config.Template.HTMLPath, httpCtx.TemplateFile)
+		templateFilePath = filepath.Join(GetConfig().Template.HTMLPath, httpCtx.TemplateFile)
 	}
 	if !common.IsExist(templateFilePath) {
 		httpCtx.ThrowCheck(500, "system error")
@@ -160,8 +160,8 @@ func (httpCtx *HTTPContext) renderFile() (t *template.Template) {
 	} else {
 		t = template.Must(template.New(filepath.Base(httpCtx.TemplateFile)).Funcs(httpCtx.FuncMap).ParseFiles(templateFilePath))
 	}
-	if len(Config.Template.WidgetsPath) > 0 {
-		t = template.Must(t.ParseGlob(Config.Template.WidgetsPath))
+	if widgets := GetConfig().Template.WidgetsPath; len(widgets) > 0 {
+		t = template.Must(t.ParseGlob(widgets))
 	}
 
 	return
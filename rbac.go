@@ -0,0 +1,230 @@
+package hfw
+
+//基于角色的权限控制
+//权限在注册路由的时候确定，默认是controller/action，action是小写的方法名(去掉For+请求方法)
+//如注册到user的controller，GetInfo和GetInfoForPOST都是user/getinfo，不管访问的是get_info还是getinfo
+//controller可以实现PermissionInterface，给action声明权限名
+//角色和权限的对应关系来自配置Rbac.Roles，或者通过LoadRbac从数据库等加载
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	logger "github.com/hsyan2008/go-logger"
+)
+
+//RbacGuestRole 未登录或者没有任何角色的用户
+const RbacGuestRole = "guest"
+
+//ErrPermissionDenied ..
+var ErrPermissionDenied = errors.New("permission denied")
+
+//PermissionInterface controller可选实现
+//返回action方法名(如Index、DeleteForPOST)对应的权限名，未声明的action使用controller/action作为权限
+type PermissionInterface interface {
+	Permissions() map[string]string
+}
+
+//RbacLoader 用于从外部(如数据库)加载角色权限，db.NewRbacLoader实现了该接口
+type RbacLoader interface {
+	LoadRbac() (map[string][]string, error)
+}
+
+var rbac = struct {
+	l        *sync.RWMutex
+	roles    map[string][]string
+	roleFunc func(*HTTPContext) []string
+	//LoadRbac加载的，重载配置的时候要合并回来
+	loaded map[string][]string
+}{
+	l:     &sync.RWMutex{},
+	roles: make(map[string][]string),
+}
+
+//AddRolePermission 给角色增加权限
+func AddRolePermission(role string, permissions ...string) {
+	rbac.l.Lock()
+	defer rbac.l.Unlock()
+	addRolePermission(rbac.roles, role, permissions...)
+}
+
+func addRolePermission(roles map[string][]string, role string, permissions ...string) {
+	for _, p := range permissions {
+		p = strings.ToLower(strings.Trim(p, "/ "))
+		if len(p) == 0 {
+			continue
+		}
+		roles[role] = append(roles[role], p)
+	}
+}
+
+//SetRolePermissions 替换所有角色的权限
+func SetRolePermissions(m map[string][]string) {
+	roles := make(map[string][]string)
+	for role, permissions := range m {
+		addRolePermission(roles, role, permissions...)
+	}
+	rbac.l.Lock()
+	rbac.roles = roles
+	rbac.l.Unlock()
+}
+
+//LoadRbac 加载外部的角色权限，并和配置里的合并，重载配置后也会保留
+func LoadRbac(loader RbacLoader) (err error) {
+	m, err := loader.LoadRbac()
+	if err != nil {
+		return
+	}
+	rbac.l.Lock()
+	rbac.loaded = m
+	rbac.l.Unlock()
+	reloadRbac(GetConfig().Rbac.Roles)
+	logger.Infof("load rbac %d roles", len(m))
+
+	return
+}
+
+//配置里的角色权限加上LoadRbac加载的，一次替换，避免中间有请求没有权限
+func reloadRbac(m map[string][]string) {
+	roles := make(map[string][]string)
+	for role, permissions := range m {
+		addRolePermission(roles, role, permissions...)
+	}
+	rbac.l.Lock()
+	defer rbac.l.Unlock()
+	for role, permissions := range rbac.loaded {
+		addRolePermission(roles, role, permissions...)
+	}
+	rbac.roles = roles
+}
+
+//SetRbacRoleFunc 自定义获取当前用户角色的方法，默认从session里取
+func SetRbacRoleFunc(f func(*HTTPContext) []string) {
+	rbac.l.Lock()
+	defer rbac.l.Unlock()
+	rbac.roleFunc = f
+}
+
+//HasPermission 判断角色列表里是否有任意一个拥有该权限
+func HasPermission(roles []string, permission string) bool {
+	rbac.l.RLock()
+	defer rbac.l.RUnlock()
+	for _, role := range roles {
+		for _, p := range rbac.roles[role] {
+			if matchPermission(p, permission) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//GetRoleRoutes 列出每个角色可以访问的路由
+//带请求方法的路由格式为 METHOD controller/action
+func GetRoleRoutes() map[string][]string {
+	rbac.l.RLock()
+	roles := make([]string, 0, len(rbac.roles))
+	for role := range rbac.roles {
+		roles = append(roles, role)
+	}
+	rbac.l.RUnlock()
+
+	routes := getRoutes()
+	m := make(map[string][]string, len(roles))
+	for _, role := range roles {
+		m[role] = []string{}
+		for _, r := range routes {
+			if HasPermission([]string{role}, r.permission) {
+				m[role] = append(m[role], r.route)
+			}
+		}
+	}
+
+	return m
+}
+
+type routeInfo struct {
+	route      string
+	permission string
+	handler    string
+}
+
+func getRoutes() (routes []routeInfo) {
+	for path, ins := range routeMap {
+		routes = append(routes, routeInfo{route: path, permission: ins.permission, handler: ins.controllerName + "." + ins.methodName})
+	}
+	for path, ins := range routeMapMethod {
+		idx := strings.LastIndex(path, "for")
+		route := path[:idx]
+		routes = append(routes, routeInfo{
+			route:      strings.ToUpper(path[idx+3:]) + " " + route,
+			permission: ins.permission,
+			handler:    ins.controllerName + "." + ins.methodName,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].route < routes[j].route
+	})
+
+	return
+}
+
+//p是角色拥有的权限，可以是*或者以/*结尾的前缀
+func matchPermission(p, permission string) bool {
+	if p == "*" || p == permission {
+		return true
+	}
+	if strings.HasSuffix(p, "/*") {
+		return strings.HasPrefix(permission, p[:len(p)-1])
+	}
+
+	return false
+}
+
+func getRoles(httpCtx *HTTPContext) (roles []string) {
+	rbac.l.RLock()
+	f := rbac.roleFunc
+	rbac.l.RUnlock()
+	if f != nil {
+		roles = f(httpCtx)
+	} else if httpCtx.Session != nil {
+		key := GetConfig().Rbac.SessionKey
+		if len(key) == 0 {
+			key = "roles"
+		}
+		switch v := httpCtx.Session.Get(key).(type) {
+		case string:
+			for _, role := range strings.Split(v, ",") {
+				if role = strings.TrimSpace(role); len(role) > 0 {
+					roles = append(roles, role)
+				}
+			}
+		case []string:
+			roles = v
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{RbacGuestRole}
+	}
+
+	return
+}
+
+//在action执行前检查权限，NotFound不检查
+func checkPermission(httpCtx *HTTPContext, ins instance, action string) {
+	if !GetConfig().Rbac.Enable || action == "NotFound" {
+		return
+	}
+	roles := getRoles(httpCtx)
+	permission := ins.permission
+	if HasPermission(roles, permission) {
+		return
+	}
+	logger.Warnf("roles: %v has no permission: %s", roles, permission)
+	httpCtx.ResponseWriter.WriteHeader(http.StatusForbidden)
+	httpCtx.IsError = true
+	httpCtx.ThrowCheck(403, ErrPermissionDenied)
+}
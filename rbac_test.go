@@ -0,0 +1,145 @@
+package hfw
+
+import (
+	"reflect"
+	"testing"
+)
+
+type rbacTestController struct {
+	Controller
+}
+
+func (ctl *rbacTestController) GetInfo(httpCtx *HTTPContext) {}
+
+func (ctl *rbacTestController) DeleteForPOST(httpCtx *HTTPContext) {}
+
+func (ctl *rbacTestController) Export(httpCtx *HTTPContext) {}
+
+func (ctl *rbacTestController) Permissions() map[string]string {
+	return map[string]string{"Export": "Report/Export"}
+}
+
+func TestMatchPermission(t *testing.T) {
+	cases := []struct {
+		p, permission string
+		want          bool
+	}{
+		{"*", "user/getinfo", true},
+		{"user/getinfo", "user/getinfo", true},
+		{"user/getinfo", "user/get_info", false},
+		{"user/*", "user/delete", true},
+		{"user/*", "users/delete", false},
+		{"user/*", "user", false},
+		{"user", "user/delete", false},
+	}
+	for _, c := range cases {
+		if got := matchPermission(c.p, c.permission); got != c.want {
+			t.Errorf("matchPermission(%q, %q) = %v, want %v", c.p, c.permission, got, c.want)
+		}
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	defer SetRolePermissions(nil)
+	SetRolePermissions(map[string][]string{
+		"admin":  {"*"},
+		"editor": {"/User/* ", "report/export"},
+		"guest":  {"user/getinfo"},
+	})
+
+	cases := []struct {
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{[]string{"admin"}, "order/delete", true},
+		{[]string{"editor"}, "user/delete", true},
+		{[]string{"editor"}, "report/export", true},
+		{[]string{"editor"}, "order/delete", false},
+		{[]string{"guest"}, "user/delete", false},
+		{[]string{"guest", "editor"}, "user/delete", true},
+		{[]string{"nobody"}, "user/getinfo", false},
+		{nil, "user/getinfo", false},
+	}
+	for _, c := range cases {
+		if got := HasPermission(c.roles, c.permission); got != c.want {
+			t.Errorf("HasPermission(%v, %q) = %v, want %v", c.roles, c.permission, got, c.want)
+		}
+	}
+}
+
+func TestGetRoleRoutes(t *testing.T) {
+	defer SetRolePermissions(nil)
+	if err := Handler("user", &rbacTestController{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//小写和下划线两种路由、不同请求方法都用同一个权限
+	for path, want := range map[string]string{
+		"user/getinfo":           "user/getinfo",
+		"user/get_info":          "user/getinfo",
+		"user/export":            "report/export",
+		"user/deleteforPOST":     "user/delete",
+		"user/permissionsforGET": "",
+	} {
+		ins, ok := routeMap[path]
+		if !ok {
+			ins, ok = routeMapMethod[path]
+		}
+		if want == "" {
+			if ok {
+				t.Errorf("%s should not be registered", path)
+			}
+			continue
+		}
+		if !ok || ins.permission != want {
+			t.Errorf("%s permission = %q, want %q", path, ins.permission, want)
+		}
+	}
+
+	SetRolePermissions(map[string][]string{
+		"admin":  {"*"},
+		"editor": {"user/*"},
+		"guest":  {"user/getinfo"},
+		"report": {"report/export"},
+	})
+	got := GetRoleRoutes()
+	want := map[string][]string{
+		"admin":  {"POST user/delete", "user/export", "user/get_info", "user/getinfo"},
+		"editor": {"POST user/delete", "user/get_info", "user/getinfo"},
+		"guest":  {"user/get_info", "user/getinfo"},
+		"report": {"user/export"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetRoleRoutes = %v, want %v", got, want)
+	}
+}
+
+type rbacTestLoader map[string][]string
+
+func (l rbacTestLoader) LoadRbac() (map[string][]string, error) {
+	return l, nil
+}
+
+func TestReloadRbacKeepsLoaded(t *testing.T) {
+	defer func() {
+		rbac.loaded = nil
+		SetRolePermissions(nil)
+	}()
+	if err := LoadRbac(rbacTestLoader{"editor": {"user/*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	//重载配置的时候只替换配置里的角色
+	reloadRbac(map[string][]string{"guest": {"user/getinfo"}})
+	if !HasPermission([]string{"editor"}, "user/delete") {
+		t.Fatal("roles loaded by LoadRbac should be kept after reload")
+	}
+	if !HasPermission([]string{"guest"}, "user/getinfo") {
+		t.Fatal("roles in config should be reloaded")
+	}
+	reloadRbac(nil)
+	if HasPermission([]string{"guest"}, "user/getinfo") {
+		t.Fatal("roles removed from config should be removed")
+	}
+}
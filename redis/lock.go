@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrLockNotHeld 锁已经过期或者被其他实例持有
+var ErrLockNotHeld = errors.New("redis lock not held")
+
+//Lock 基于SetNxEx的分布式锁
+//每次加锁从key:fencing取一个递增的token，作为锁的值，也可以传给下游用于拒绝过期持有者的写入
+//续期和释放先比较token，不是原子的，极端情况下可能续期或者释放了别人刚拿到的锁，ttl不要设得太短
+type Lock struct {
+	ins RedisInterface
+	key string
+	ttl int
+
+	mu    *sync.Mutex
+	token int64
+}
+
+//NewLock ttl单位秒，最小1秒
+func NewLock(ins RedisInterface, key string, ttl int) *Lock {
+	if ttl < 1 {
+		ttl = 1
+	}
+	return &Lock{
+		ins: ins,
+		key: key,
+		ttl: ttl,
+		mu:  new(sync.Mutex),
+	}
+}
+
+//TryLock 不等待，拿到锁返回true
+func (l *Lock) TryLock() (ok bool, err error) {
+	if l.ins == nil {
+		return false, errors.New("redis instance need init")
+	}
+	token, err := l.ins.Incr(l.key + ":fencing")
+	if err != nil {
+		return
+	}
+	ok, err = l.ins.SetNxEx(l.key, token, l.ttl)
+	if err != nil || !ok {
+		return
+	}
+	l.mu.Lock()
+	l.token = token
+	l.mu.Unlock()
+
+	return
+}
+
+//Token 当前持有的fencing token，没有持有为0
+func (l *Lock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+//Refresh 续期为ttl秒
+func (l *Lock) Refresh() error {
+	return l.expire(l.ttl)
+}
+
+//ExpireAfter 持有的锁改为d后过期，不足1秒的直接释放
+func (l *Lock) ExpireAfter(d time.Duration) error {
+	if d < time.Second {
+		return l.Unlock()
+	}
+
+	return l.expire(int((d + time.Second - 1) / time.Second))
+}
+
+func (l *Lock) expire(sec int) (err error) {
+	if err = l.check(); err != nil {
+		return
+	}
+	ok, err := l.ins.Expire(l.key, int32(sec))
+	if err != nil {
+		return
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+
+	return
+}
+
+//Unlock 释放锁，锁已经不是自己的返回ErrLockNotHeld
+func (l *Lock) Unlock() (err error) {
+	if err = l.check(); err != nil {
+		return
+	}
+	defer func() {
+		l.mu.Lock()
+		l.token = 0
+		l.mu.Unlock()
+	}()
+	_, err = l.ins.Del(l.key)
+
+	return
+}
+
+//检查锁的值是否还是自己的token
+func (l *Lock) check() error {
+	token := l.Token()
+	if token == 0 {
+		return ErrLockNotHeld
+	}
+	v, err := l.ins.Get(l.key)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(int64); !ok || t != token {
+		l.mu.Lock()
+		l.token = 0
+		l.mu.Unlock()
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"sync"
+	"testing"
+)
+
+//只实现锁用到的方法
+type memRedis struct {
+	RedisInterface
+	mu   sync.Mutex
+	data map[string]interface{}
+}
+
+func (m *memRedis) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, _ := m.data[key].(int64)
+	m.data[key] = v + 1
+	return v + 1, nil
+}
+
+func (m *memRedis) SetNxEx(key string, value interface{}, expiration int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; ok {
+		return false, nil
+	}
+	m.data[key] = value
+	return true, nil
+}
+
+func (m *memRedis) Get(key string) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memRedis) Expire(key string, expiration int32) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.data[key]
+	return ok, nil
+}
+
+func (m *memRedis) Del(keys ...string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return true, nil
+}
+
+func TestLock(t *testing.T) {
+	ins := &memRedis{data: make(map[string]interface{})}
+	a := NewLock(ins, "job", 10)
+	b := NewLock(ins, "job", 10)
+
+	if ok, err := a.TryLock(); !ok || err != nil {
+		t.Fatalf("a lock: %v %v", ok, err)
+	}
+	if ok, _ := b.TryLock(); ok {
+		t.Fatal("b should not get the lock")
+	}
+	if err := a.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(); err != ErrLockNotHeld {
+		t.Fatalf("unlock twice: %v", err)
+	}
+
+	if ok, _ := b.TryLock(); !ok {
+		t.Fatal("b should get the lock")
+	}
+	if b.Token() <= 1 {
+		t.Fatalf("fencing token must increase, got %d", b.Token())
+	}
+
+	//模拟过期后被a拿到，b不能再续期和释放
+	_, _ = ins.Del("job")
+	if ok, _ := a.TryLock(); !ok {
+		t.Fatal("a should get the lock")
+	}
+	if err := b.Refresh(); err != ErrLockNotHeld {
+		t.Fatalf("b refresh: %v", err)
+	}
+	if err := b.Unlock(); err != ErrLockNotHeld {
+		t.Fatalf("b unlock: %v", err)
+	}
+	if v, _ := ins.Get("job"); v != a.Token() {
+		t.Fatalf("lock value %v, want %d", v, a.Token())
+	}
+}
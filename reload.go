@@ -0,0 +1,216 @@
+package hfw
+
+//配置重载
+//Reload.Enable开启后，监听配置文件的修改，或者kill -USR1 pid手动触发
+//只有部分配置支持重载，其他配置修改后需要重启，会记录错误日志并忽略
+import (
+	"context"
+	"html/template"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//ConfigChangeEvent 配置重载后通知订阅方
+type ConfigChangeEvent struct {
+	Old configs.AllConfig
+	New configs.AllConfig
+	//有修改的配置名，如Logger、Template
+	Changed []string
+}
+
+var configSubscribers = struct {
+	l    *sync.Mutex
+	list []func(ConfigChangeEvent)
+}{
+	l: &sync.Mutex{},
+}
+
+//SubscribeConfig 订阅配置重载，只有重载成功并且有修改的时候才通知
+func SubscribeConfig(f func(ConfigChangeEvent)) {
+	configSubscribers.l.Lock()
+	defer configSubscribers.l.Unlock()
+	configSubscribers.list = append(configSubscribers.list, f)
+}
+
+//配置重载时的处理，未列出的配置不支持重载
+var reloadableConfigs = map[string]func(c configs.AllConfig){
+	"Logger": func(c configs.AllConfig) {
+		initLog()
+	},
+	"Template": func(c configs.AllConfig) {
+		templatesCache.l.Lock()
+		templatesCache.list = make(map[string]*template.Template)
+		templatesCache.l.Unlock()
+	},
+	"Rbac": func(c configs.AllConfig) {
+		reloadRbac(c.Rbac.Roles)
+	},
+	"ErrorMap": func(c configs.AllConfig) {
+		//ReloadConfig里已经检查过，这里不会出错
+		if err := loadConfigErrorMap(c.ErrorMap); err != nil {
+			logger.Error("reload ErrorMap failed:", err)
+		}
+	},
+	"Custom": func(c configs.AllConfig) {},
+	//只有Concurrence，见diffConfig
+	"Server": func(c configs.AllConfig) {
+		setConcurrenceChan(c.Server.Concurrence)
+	},
+}
+
+var reloadLock = &sync.Mutex{}
+
+//重载的时候替换Config，读Config的时候加读锁，见GetConfig
+var configLock = &sync.RWMutex{}
+
+//GetConfig 返回当前配置的副本
+//开启了配置重载的时候，请求里不要直接读Config，用GetConfig
+func GetConfig() configs.AllConfig {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return Config
+}
+
+//ReloadConfig 重新读取配置文件并应用可以重载的配置
+//有错误的时候不做任何修改
+func ReloadConfig() (err error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	logger.Info("reload config start")
+	newConfig, layers, err := readConfig()
+	if err == nil {
+		//先检查，避免部分配置已经重载才发现错误
+		err = checkErrorMap(newConfig.ErrorMap)
+	}
+	if err != nil {
+		logger.Errorf("reload config failed: %v, keep the old config", err)
+		return
+	}
+
+	//只有ReloadConfig会修改Config，这里读不需要加锁
+	oldConfig := Config
+	changed := diffConfig(oldConfig, &newConfig)
+	//RegisterConfig注册的自定义配置
+	sections, err := reloadSections(layers)
+	if err != nil {
+		logger.Errorf("reload config failed: %v, keep the old config", err)
+		return
+	}
+	if len(changed) == 0 && len(sections) == 0 {
+		logger.Info("reload config done, nothing changed")
+		return
+	}
+
+	configLock.Lock()
+	Config = newConfig
+	configLock.Unlock()
+	for _, name := range changed {
+		reloadableConfigs[name](newConfig)
+	}
+	changed = append(changed, sections...)
+	logger.Infof("reload config done, changed: %v", changed)
+
+	event := ConfigChangeEvent{Old: oldConfig, New: newConfig, Changed: changed}
+	configSubscribers.l.Lock()
+	subscribers := configSubscribers.list
+	configSubscribers.l.Unlock()
+	for _, f := range subscribers {
+		f(event)
+	}
+
+	return
+}
+
+//比较新旧配置，不支持重载的配置恢复为旧值
+//Server只有Concurrence支持重载
+func diffConfig(oldConfig configs.AllConfig, newConfig *configs.AllConfig) (changed []string) {
+	oldVal := reflect.ValueOf(oldConfig)
+	newVal := reflect.ValueOf(newConfig).Elem()
+	rt := oldVal.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name := rt.Field(i).Name
+		if name == "Server" {
+			server := newConfig.Server
+			server.Concurrence = oldConfig.Server.Concurrence
+			if !reflect.DeepEqual(server, oldConfig.Server) {
+				logger.Errorf("config Server can not be reloaded except Concurrence, restart is required, ignored")
+				newConfig.Server = oldConfig.Server
+				newConfig.Server.Concurrence = server.Concurrence
+			}
+		}
+		if reflect.DeepEqual(oldVal.Field(i).Interface(), newVal.Field(i).Interface()) {
+			continue
+		}
+		if _, ok := reloadableConfigs[name]; !ok {
+			logger.Errorf("config %s can not be reloaded, restart is required, ignored", name)
+			newVal.Field(i).Set(oldVal.Field(i))
+			continue
+		}
+		changed = append(changed, name)
+	}
+
+	return
+}
+
+//监听配置文件所在目录，编辑器保存文件可能是先删除再创建
+func watchConfig(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("watch config failed:", err)
+		return
+	}
+	defer watcher.Close()
+
+	configFiles := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, f := range getConfigFiles() {
+		configFiles[f] = true
+		dirs[filepath.Dir(f)] = true
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			logger.Warnf("watch config dir: %s failed: %v", dir, err)
+		}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	defer signal.Stop(c)
+	logger.Infof("Exec `kill -USR1 %d` will reload config", PID)
+
+	//合并短时间内的多次修改
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			_ = ReloadConfig()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if configFiles[event.Name] {
+				timer = time.After(500 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("watch config error:", err)
+		case <-timer:
+			timer = nil
+			_ = ReloadConfig()
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
 	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw2/common"
 	"github.com/hsyan2008/hfw2/grpc/server"
@@ -29,8 +30,19 @@ func Router(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
-	signalContext.WgAdd()
-	defer signalContext.WgDone()
+	//开始关闭后，已经建立的连接上的新请求不再处理
+	if signalContext.IsClosing() {
+		w.Header().Set("Connection", "close")
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	//websocket是长连接，作为后台工作，关闭时通过Ctx通知
+	if websocket.IsWebSocketUpgrade(r) {
+		defer signalContext.AddWork("websocket " + r.URL.Path)()
+	} else {
+		defer signalContext.httpWorks.add(r.Method + " " + r.URL.Path)()
+	}
 
 	if r.ProtoMajor == 2 && strings.Contains(r.Header.Get("Content-Type"), "application/grpc") && server.GetGrpcServer() != nil {
 		server.GetGrpcServer().ServeHTTP(w, r) // gRPC Server
@@ -48,6 +60,7 @@ func Router(w http.ResponseWriter, r *http.Request) {
 	httpCtx.Controller, httpCtx.Action, _ = formatURL(httpCtx.Request.URL.Path)
 	httpCtx.SignalContext = signalContext
 	httpCtx.Ctx, httpCtx.Cancel = context.WithCancel(signalContext.Ctx)
+	httpCtx.Ctx = applyContextHooks(httpCtx.Ctx)
 	defer httpCtx.Cancel()
 	initValue := []reflect.Value{
 		reflect.ValueOf(httpCtx),
@@ -56,14 +69,14 @@ func Router(w http.ResponseWriter, r *http.Request) {
 	//如果用户关闭连接
 	go closeNotify(httpCtx)
 
-	if !common.IsGoTest() && Config.Server.Concurrence > 0 {
-		err := holdConcurrenceChan(httpCtx)
+	if ch := getConcurrenceChan(); !common.IsGoTest() && ch != nil {
+		err := holdConcurrenceChan(httpCtx, ch)
 		if err != nil {
 			logger.Warn(err)
 			return
 		}
 		defer func() {
-			<-concurrenceChan
+			<-ch
 		}()
 	}
 
@@ -77,6 +90,9 @@ func Router(w http.ResponseWriter, r *http.Request) {
 
 	defer recoverPanic(reflectVal, initValue)
 
+	//没有权限的时候不执行Before
+	checkPermission(httpCtx, instance, action)
+
 	reflectVal.MethodByName("Before").Call(initValue)
 	defer reflectVal.MethodByName("After").Call(initValue)
 
@@ -119,7 +135,7 @@ func closeNotify(httpCtx *HTTPContext) {
 	}
 }
 
-func holdConcurrenceChan(httpCtx *HTTPContext) (err error) {
+func holdConcurrenceChan(httpCtx *HTTPContext, ch chan bool) (err error) {
 	select {
 	//用户关闭连接
 	case <-httpCtx.Ctx.Done():
@@ -138,7 +154,7 @@ func holdConcurrenceChan(httpCtx *HTTPContext) (err error) {
 		}
 		_ = conn.Close()
 		return errors.New("timeout")
-	case concurrenceChan <- true:
+	case ch <- true:
 		return
 	}
 }
@@ -150,6 +166,8 @@ func Handler(pattern string, handler ControllerInterface) (err error) {
 		routeInit = true
 		http.HandleFunc("/", Router)
 		http.HandleFunc("/logger/adjust", loggerAdjust)
+		//管理接口，见admin.go
+		initAdminHandlers()
 	}
 
 	controller, _, leave := formatURL(pattern)
@@ -170,18 +188,31 @@ func Handler(pattern string, handler ControllerInterface) (err error) {
 	}
 	routeMapRegister[pattern] = controllerName
 
+	var permissions map[string]string
+	p, hasPermissions := handler.(PermissionInterface)
+	if hasPermissions {
+		permissions = p.Permissions()
+	}
+
 	numMethod := rt.NumMethod()
 	//注意方法必须是大写开头，否则无法调用
 	for i := 0; i < numMethod; i++ {
 		m := rt.Method(i).Name
-		switch m {
-		case "Init", "Before", "After", "Finish", "NotFound", "ServerError":
+		switch {
+		case m == "Init", m == "Before", m == "After", m == "Finish", m == "NotFound", m == "ServerError":
+		//实现了PermissionInterface的时候Permissions不是action
+		case m == "Permissions" && hasPermissions:
 		default:
 			actions, method, isMethod := getRequestMethod(m)
 			value := instance{
 				reflectVal:     reflectVal,
 				controllerName: controllerName,
 				methodName:     rt.Method(i).Name,
+				permission:     strings.ToLower(permissions[m]),
+			}
+			//没有声明权限名的，用controller/action，小写和下划线两种路由共用一个权限
+			if len(value.permission) == 0 {
+				value.permission = fmt.Sprintf("%s/%s", controller, actions[0])
 			}
 			for _, action := range actions {
 				if isMethod {
@@ -242,14 +273,15 @@ func formatURL(url string) (controller string, action string, leave string) {
 	//去掉前缀并把url补全为2段
 	trimURL := strings.Trim(strings.ToLower(url), "/")
 	urls := strings.SplitN(trimURL, "/", 3)
+	route := GetConfig().Route
 	if len(urls) == 1 {
-		urls = append(urls, Config.Route.DefaultAction)
+		urls = append(urls, route.DefaultAction)
 	}
 	if urls[0] == "" {
-		urls[0] = Config.Route.DefaultController
+		urls[0] = route.DefaultController
 	}
 	if urls[1] == "" {
-		urls[1] = Config.Route.DefaultAction
+		urls[1] = route.DefaultAction
 	}
 	if len(urls) == 3 {
 		leave = urls[2]
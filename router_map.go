@@ -14,6 +14,8 @@ type instance struct {
 	reflectVal     reflect.Value
 	controllerName string
 	methodName     string
+	//rbac权限名，默认是controller/action
+	permission string
 }
 
 var (
@@ -22,7 +24,9 @@ var (
 	routeMapRegister = make(map[string]string)
 	routeInit        bool
 
+	//重载配置的时候会替换，所以用锁
 	concurrenceChan chan bool
+	concurrenceLock = &sync.RWMutex{}
 
 	httpCtxPool = &sync.Pool{
 		New: func() interface{} {
@@ -93,7 +97,25 @@ func DispatchRoute(httpCtx *HTTPContext) {
 	initValue := []reflect.Value{
 		reflect.ValueOf(httpCtx),
 	}
+	checkPermission(httpCtx, instance, action)
 	reflectVal.MethodByName("Before").Call(initValue)
 	defer reflectVal.MethodByName("After").Call(initValue)
 	reflectVal.MethodByName(action).Call(initValue)
 }
+
+func getConcurrenceChan() chan bool {
+	concurrenceLock.RLock()
+	defer concurrenceLock.RUnlock()
+	return concurrenceChan
+}
+
+//正在处理的请求会在原来的chan上释放
+func setConcurrenceChan(n uint) {
+	concurrenceLock.Lock()
+	defer concurrenceLock.Unlock()
+	if n > 0 {
+		concurrenceChan = make(chan bool, n)
+	} else {
+		concurrenceChan = nil
+	}
+}
@@ -1,14 +1,16 @@
 package hfw
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/BurntSushi/toml"
 	"github.com/google/gops/agent"
 	logger "github.com/hsyan2008/go-logger"
 	"github.com/hsyan2008/hfw2/common"
@@ -32,9 +34,32 @@ var PID = os.Getpid()
 
 var Config configs.AllConfig
 
+var isPrintConfig bool
+
+var isCheckConfig bool
+
 func init() {
+	registerBuiltinCommands()
 	parseFlag()
-	loadConfig()
+
+	args := flag.Args()
+	if isCheckConfig {
+		args = []string{"config", "check"}
+	} else if isPrintConfig {
+		args = []string{"config", "print"}
+	}
+	//不需要加载配置或者自己加载配置的内置命令，执行后退出
+	if cmd, cmdArgs, err := findCommand(args); err == nil && cmd.early {
+		if err = cmd.run(cmdArgs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err := loadConfig(); err != nil {
+		panic(err)
+	}
 	initLog()
 }
 
@@ -49,6 +74,19 @@ func parseFlag() {
 		flag.StringVar(&VERSION, "v", "0.1", "set version")
 	}
 
+	flag.Var(&configSets, "set", "override config, e.g -set Db.Password=xxx, can be repeated")
+	flag.BoolVar(&isPrintConfig, "print-config", false, "same as command: config print")
+	flag.BoolVar(&isCheckConfig, "check-config", false, "same as command: config check")
+	flag.StringVar(&runMode, "mode", RunModeAll, "run mode, all: http and workers, http: only http, worker: only workers")
+
+	flag.Usage = func() {
+		printCommands(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	//go test的参数在init之后才注册，需要提前注册，否则flag.Parse会失败
+	if common.IsGoTest() {
+		testing.Init()
+	}
 	flag.Parse()
 }
 
@@ -90,33 +128,52 @@ func initLog() {
 	logger.SetPrefix(HOSTNAME + "/" + VERSION)
 }
 
-func loadConfig() {
-	if len(ENVIRONMENT) == 0 {
-		if common.IsExist(filepath.Join(APPPATH, "config")) {
-			if common.IsGoRun() || common.IsGoTest() {
-				ENVIRONMENT = DEV
-			} else {
-				panic("please specify env")
-			}
-		} else {
-			return
-		}
+//没有指定环境的时候，go run和go test默认为dev，没有config目录返回false
+func initEnvironment() (hasConfig bool, err error) {
+	if len(ENVIRONMENT) > 0 {
+		return true, nil
 	}
-	configPath := filepath.Join(APPPATH, "config", ENVIRONMENT, "config.toml")
-	if common.IsExist(configPath) {
-		_, err := toml.DecodeFile(configPath, &Config)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		logger.Warnf("config file: %s not exist", configPath)
+	if !common.IsDir(filepath.Join(APPPATH, "config")) {
+		return false, nil
+	}
+	if common.IsGoRun() || common.IsGoTest() {
+		ENVIRONMENT = DEV
+		return true, nil
 	}
 
+	return false, errors.New("please specify env")
+}
+
+func loadConfig() (err error) {
+	hasConfig, err := initEnvironment()
+	if err != nil || !hasConfig {
+		return
+	}
+	var layers []*configLayer
+	Config, layers, err = readConfig()
+	if err != nil {
+		return
+	}
+	configSections.layers = layers
+
 	initConfig()
+
+	return
 }
 
-//Run start
+//Run 执行命令行指定的子命令，默认是serve
 func Run() (err error) {
+	cmd, args, err := findCommand(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	return cmd.run(args)
+}
+
+//启动http服务和worker
+func runServe() (err error) {
 
 	logger.Info("Starting ...")
 	defer logger.Info("Shutdowned!")
@@ -135,22 +192,72 @@ func Run() (err error) {
 	defer signalContext.Shutdowned()
 
 	if Config.HotDeploy.Enable {
-		go HotDeploy(Config.HotDeploy)
+		hotDeployConfig := Config.HotDeploy
+		_ = signalContext.RegisterComponent(Component{
+			Name: "hot_deploy",
+			Run: func(ctx context.Context) error {
+				hotDeploy(ctx, hotDeployConfig)
+				return nil
+			},
+		})
+	}
+
+	if Config.Reload.Enable {
+		_ = signalContext.RegisterComponent(Component{
+			Name: "config_watcher",
+			Run: func(ctx context.Context) error {
+				watchConfig(ctx)
+				return nil
+			},
+		})
 	}
 
-	if len(Config.Server.Address) == 0 {
-		logger.Warn("server address is nil")
+	switch runMode {
+	case RunModeAll, RunModeHTTP, RunModeWorker:
+	default:
+		err = fmt.Errorf("run mode %q unknown, must be all/http/worker", runMode)
+		logger.Fatal(err)
+		return
+	}
+
+	if runMode != RunModeHTTP && hasWorkers() {
+		_ = signalContext.RegisterComponent(Component{
+			Name: "workers",
+			OnStart: func(ctx context.Context) error {
+				startWorkers(signalContext)
+				return nil
+			},
+		})
+	}
+
+	//组件在关闭的第3阶段停止
+	if err = signalContext.startComponents(); err != nil {
+		logger.Fatal(err)
+		return
+	}
+
+	if runMode == RunModeWorker || len(Config.Server.Address) == 0 {
+		if runMode != RunModeWorker {
+			logger.Warn("server address is nil")
+		}
+		//只有worker的时候，等待信号退出
+		if runMode != RunModeHTTP && hasWorkers() {
+			logger.Infof("Running workers only")
+			<-signalContext.Ctx.Done()
+		}
 		return
 	}
 
 	//启动http
 	signalContext.IsHTTP = true
 
-	if Config.Server.Concurrence > 0 {
-		concurrenceChan = make(chan bool, Config.Server.Concurrence)
-	}
+	setConcurrenceChan(Config.Server.Concurrence)
 
-	err = serve.Start(Config)
+	if Config.Server.Upgrade {
+		err = serveUpgradable()
+	} else {
+		err = serve.Start(Config)
+	}
 
 	//如果未启动服务，就触发退出
 	if err != nil && err != http.ErrServerClosed {
@@ -160,41 +267,40 @@ func Run() (err error) {
 	return
 }
 
-func initConfig() {
+//设置默认值，转换路径等，不能有副作用，重载配置的时候也会调用
+func formatConfig(c *configs.AllConfig) {
 	//设置默认路由
-	if len(Config.Route.DefaultController) == 0 {
-		Config.Route.DefaultController = "index"
+	if len(c.Route.DefaultController) == 0 {
+		c.Route.DefaultController = "index"
 	} else {
-		Config.Route.DefaultController = strings.ToLower(Config.Route.DefaultController)
+		c.Route.DefaultController = strings.ToLower(c.Route.DefaultController)
 	}
-	if len(Config.Route.DefaultAction) == 0 {
-		Config.Route.DefaultAction = "index"
+	if len(c.Route.DefaultAction) == 0 {
+		c.Route.DefaultAction = "index"
 	} else {
-		Config.Route.DefaultAction = strings.ToLower(Config.Route.DefaultAction)
+		c.Route.DefaultAction = strings.ToLower(c.Route.DefaultAction)
 	}
 
 	//转为绝对路径
-	if !filepath.IsAbs(Config.Template.HTMLPath) {
-		Config.Template.HTMLPath = filepath.Join(APPPATH, Config.Template.HTMLPath)
+	if !filepath.IsAbs(c.Template.HTMLPath) {
+		c.Template.HTMLPath = filepath.Join(APPPATH, c.Template.HTMLPath)
 	}
-	if len(Config.Template.WidgetsPath) > 0 {
-		if !filepath.IsAbs(Config.Template.WidgetsPath) {
-			Config.Template.WidgetsPath = filepath.Join(APPPATH, Config.Template.WidgetsPath)
-		}
-		m, err := filepath.Glob(Config.Template.WidgetsPath)
-		if err != nil || len(m) == 0 {
-			panic("error WidgetsPath")
+	if len(c.Template.WidgetsPath) > 0 {
+		if !filepath.IsAbs(c.Template.WidgetsPath) {
+			c.Template.WidgetsPath = filepath.Join(APPPATH, c.Template.WidgetsPath)
 		}
 	}
 
-	if len(Config.Server.Port) > 0 && !strings.Contains(Config.Server.Port, ":") {
-		Config.Server.Port = ":" + Config.Server.Port
+	if len(c.Server.Port) > 0 && !strings.Contains(c.Server.Port, ":") {
+		c.Server.Port = ":" + c.Server.Port
 	}
 	//兼容
-	if len(Config.Server.Address) == 0 && len(Config.Server.Port) > 0 {
-		Config.Server.Address = Config.Server.Port
+	if len(c.Server.Address) == 0 && len(c.Server.Port) > 0 {
+		c.Server.Address = c.Server.Port
 	}
+}
 
+func initConfig() {
 	certFile := Config.Server.HTTPSCertFile
 	keyFile := Config.Server.HTTPSKeyFile
 	if len(certFile) > 0 && len(keyFile) > 0 {
@@ -207,11 +313,25 @@ func initConfig() {
 		}
 	}
 
+	if len(Config.Rbac.Roles) > 0 {
+		SetRolePermissions(Config.Rbac.Roles)
+	}
+
+	if err := loadConfigErrorMap(Config.ErrorMap); err != nil {
+		panic(err)
+	}
+
 	var err error
 	if len(Config.Redis.Server) > 0 {
 		redis.DefaultRedisIns, err = redis.NewRedis(Config.Redis)
 		if err != nil {
 			panic("error redis config:" + err.Error())
 		}
+		if c, ok := redis.DefaultRedisIns.(interface{ Close() }); ok {
+			signalContext.OnClose("redis", func() error {
+				c.Close()
+				return nil
+			})
+		}
 	}
 }
@@ -0,0 +1,115 @@
+package hfw
+
+//配置文件里的密码等可以加密，格式为ENC(base64密文)，加载配置的时候自动解密
+//密钥取环境变量HFW_CONFIG_KEY，或者HFW_CONFIG_KEY_FILE指定的文件，默认config/.key，长度不能小于16
+//加解密命令，不带值的时候从标准输入按行读取，也可以写成config encrypt、config decrypt
+//  ./app encrypt plaintext
+//  ./app decrypt 'ENC(...)'
+//更换密钥
+//  HFW_CONFIG_KEY=old ./app decrypt 'ENC(...)' | HFW_CONFIG_KEY=new ./app encrypt
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+func getConfigKey() (key string, err error) {
+	key = os.Getenv("HFW_CONFIG_KEY")
+	if len(key) > 0 {
+		return
+	}
+	keyFile := os.Getenv("HFW_CONFIG_KEY_FILE")
+	if len(keyFile) == 0 {
+		keyFile = filepath.Join(APPPATH, "config", ".key")
+		if !common.IsExist(keyFile) {
+			return
+		}
+	}
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("read config key file failed: %v", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+//解密过的配置路径，打印配置的时候隐藏，key是自定义配置名，AllConfig为空
+var decryptedPaths = struct {
+	l    *sync.Mutex
+	list map[string]map[string]bool
+}{
+	l:    &sync.Mutex{},
+	list: make(map[string]map[string]bool),
+}
+
+//解密配置里所有ENC(...)格式的值，name是自定义配置名，AllConfig为空
+func decryptConfig(name string, v interface{}) error {
+	key, err := getConfigKey()
+	if err != nil {
+		return err
+	}
+	paths, err := configs.DecryptAllPaths(v, key)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	decryptedPaths.l.Lock()
+	defer decryptedPaths.l.Unlock()
+	if decryptedPaths.list[name] == nil {
+		decryptedPaths.list[name] = make(map[string]bool)
+	}
+	for _, p := range paths {
+		decryptedPaths.list[name][p] = true
+	}
+
+	return nil
+}
+
+func getDecryptedPaths(name string) (paths []string) {
+	decryptedPaths.l.Lock()
+	defer decryptedPaths.l.Unlock()
+	for p := range decryptedPaths.list[name] {
+		paths = append(paths, p)
+	}
+
+	return
+}
+
+//处理加解密命令，values为空的时候从r读取
+func runSecretCommand(encrypt bool, values []string, r io.Reader, w io.Writer) (err error) {
+	key, err := getConfigKey()
+	if err != nil {
+		return
+	}
+
+	f := configs.DecryptValue
+	if encrypt {
+		f = configs.EncryptValue
+	}
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			values = append(values, strings.TrimSpace(scanner.Text()))
+		}
+		if err = scanner.Err(); err != nil {
+			return
+		}
+	}
+	for _, v := range values {
+		s, err := f(key, v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, s)
+	}
+
+	return
+}
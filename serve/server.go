@@ -19,6 +19,9 @@ func Start(config configs.AllConfig) (err error) {
 	s := gracehttp.NewServer(addr, nil, readTimeout, writeTimeout)
 
 	if common.IsExist(config.Server.HTTPSCertFile) && common.IsExist(config.Server.HTTPSKeyFile) {
+		if err = checkKeyNotEncrypted(config.Server.HTTPSKeyFile); err != nil {
+			return
+		}
 		logger.Info("Listen on https", config.Server.Address)
 		err = s.ListenAndServeTLS(config.Server.HTTPSCertFile, config.Server.HTTPSKeyFile)
 	} else {
@@ -0,0 +1,50 @@
+package serve
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//loadKeyPair 加载证书，私钥是加密的PEM时用phrase(Server.HTTPSPhrase)解密
+func loadKeyPair(certFile, keyFile, phrase string) (cert tls.Certificate, err error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return
+	}
+	block, _ := pem.Decode(keyPEM)
+	//openssl genrsa -aes256生成的传统加密PEM
+	if block != nil && x509.IsEncryptedPEMBlock(block) {
+		if len(phrase) == 0 {
+			return cert, fmt.Errorf("%s is encrypted, Server.HTTPSPhrase is required", keyFile)
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(phrase))
+		if err != nil {
+			return cert, fmt.Errorf("decrypt %s failed: %v", keyFile, err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+//gracehttp只能传文件名，不支持加密的私钥
+func checkKeyNotEncrypted(keyFile string) error {
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block != nil && x509.IsEncryptedPEMBlock(block) {
+		return errors.New("encrypted Server.HTTPSKeyFile with Server.HTTPSPhrase requires Server.Upgrade")
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadKeyPair(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	encKeyFile := filepath.Join(dir, "key_enc.pem")
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("123456"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	_ = ioutil.WriteFile(encKeyFile, pem.EncodeToMemory(block), 0600)
+
+	if _, err = loadKeyPair(certFile, keyFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadKeyPair(certFile, encKeyFile, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadKeyPair(certFile, encKeyFile, ""); err == nil {
+		t.Fatal("encrypted key without phrase should fail")
+	}
+	if _, err = loadKeyPair(certFile, encKeyFile, "wrong"); err == nil {
+		t.Fatal("wrong phrase should fail")
+	}
+	if checkKeyNotEncrypted(keyFile) != nil || checkKeyNotEncrypted(encKeyFile) == nil {
+		t.Fatal("checkKeyNotEncrypted failed")
+	}
+}
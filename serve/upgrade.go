@@ -0,0 +1,182 @@
+package serve
+
+//不依赖gracehttp的http服务，支持新进程就绪后再交接
+//旧进程通过fd 3把监听传给新进程，fd 4是管道，新进程就绪后写入ready
+//新进程在超时内没有就绪或者退出了，旧进程杀掉新进程并继续服务
+//grpc和http共享端口，所以也一起交接
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/common"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//UpgradeEnvKey 新进程的环境变量，表示监听从旧进程继承
+const UpgradeEnvKey = "HFW_UPGRADE"
+
+const (
+	upgradeListenerFd = 3
+	upgradeReadyFd    = 4
+	upgradeReadyMsg   = "ready"
+)
+
+//只在启动的时候判断，避免传给再下一个进程
+var isUpgradeChild = os.Getenv(UpgradeEnvKey) == "1"
+
+func init() {
+	_ = os.Unsetenv(UpgradeEnvKey)
+}
+
+//IsUpgradeChild 是否是Upgrade启动的新进程
+func IsUpgradeChild() bool {
+	return isUpgradeChild
+}
+
+//Server 支持Upgrade的http服务
+type Server struct {
+	httpServer *http.Server
+	ln         *net.TCPListener
+	//证书在httpServer.TLSConfig里
+	isTLS bool
+}
+
+//NewServer 监听Server.Address，Upgrade启动的新进程使用继承的监听
+func NewServer(config configs.AllConfig) (s *Server, err error) {
+	s = &Server{
+		httpServer: &http.Server{
+			Addr:         config.Server.Address,
+			ReadTimeout:  config.Server.ReadTimeout * time.Second,
+			WriteTimeout: config.Server.WriteTimeout * time.Second,
+		},
+	}
+	if common.IsExist(config.Server.HTTPSCertFile) && common.IsExist(config.Server.HTTPSKeyFile) {
+		cert, err := loadKeyPair(config.Server.HTTPSCertFile, config.Server.HTTPSKeyFile, config.Server.HTTPSPhrase)
+		if err != nil {
+			return nil, err
+		}
+		s.httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		s.isTLS = true
+	}
+
+	var ln net.Listener
+	if isUpgradeChild {
+		f := os.NewFile(upgradeListenerFd, "listener")
+		ln, err = net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("net.FileListener error: %v", err)
+		}
+	} else {
+		ln, err = net.Listen("tcp", config.Server.Address)
+		if err != nil {
+			return nil, fmt.Errorf("net.Listen error: %v", err)
+		}
+	}
+	var ok bool
+	if s.ln, ok = ln.(*net.TCPListener); !ok {
+		_ = ln.Close()
+		return nil, errors.New("listener is not tcp")
+	}
+
+	return
+}
+
+//Serve 阻塞直到Shutdown，返回http.ErrServerClosed
+func (s *Server) Serve() error {
+	if s.isTLS {
+		logger.Info("Listen on https", s.ln.Addr())
+		return s.httpServer.ServeTLS(s.ln, "", "")
+	}
+	logger.Info("Listen on http", s.ln.Addr())
+
+	return s.httpServer.Serve(s.ln)
+}
+
+//Shutdown 停止接收新连接，等待连接空闲后关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+//Upgrade 用相同的参数启动新进程并传递监听，新进程在timeout内调用NotifyReady返回nil
+//否则杀掉新进程并返回错误，当前进程可以继续服务
+func (s *Server) Upgrade(timeout time.Duration) (pid int, err error) {
+	lnFile, err := s.ln.File()
+	if err != nil {
+		return 0, fmt.Errorf("get listener file failed: %v", err)
+	}
+	defer lnFile.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, UpgradeEnvKey+"=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	cmd.Env = append(cmd.Env, UpgradeEnvKey+"=1")
+	cmd.ExtraFiles = []*os.File{lnFile, w}
+	err = cmd.Start()
+	//关闭写端，新进程退出的时候读端会收到EOF
+	_ = w.Close()
+	if err != nil {
+		return 0, fmt.Errorf("start new process failed: %v", err)
+	}
+	logger.Infof("new process %d started, waiting ready in %s", cmd.Process.Pid, timeout)
+
+	readyChan := make(chan error, 1)
+	go func() {
+		buf := make([]byte, len(upgradeReadyMsg))
+		if _, err := io.ReadFull(r, buf); err != nil || string(buf) != upgradeReadyMsg {
+			readyChan <- errors.New("new process exited before ready")
+			return
+		}
+		readyChan <- nil
+	}()
+	select {
+	case err = <-readyChan:
+	case <-time.After(timeout):
+		err = fmt.Errorf("new process not ready in %s", timeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return 0, err
+	}
+	//新进程退出的时候回收
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	return cmd.Process.Pid, nil
+}
+
+//NotifyReady 新进程就绪后通知旧进程，不是Upgrade启动的进程什么也不做
+func NotifyReady() error {
+	if !isUpgradeChild {
+		return nil
+	}
+	f := os.NewFile(upgradeReadyFd, "ready")
+	defer f.Close()
+	_, err := f.Write([]byte(upgradeReadyMsg))
+
+	return err
+}
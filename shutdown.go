@@ -0,0 +1,189 @@
+package hfw
+
+//分阶段关闭，每个阶段的超时见configs.ShutdownConfig
+//1. 停止接收新请求，监听由gracehttp关闭，已经建立的连接上的新请求返回503
+//2. 等待处理中的http和grpc请求
+//3. 取消SignalContext.Ctx通知后台任务，停止组件，等待后台任务和组件结束，共用一个超时
+//4. 关闭数据库、redis等连接池
+//超时的时候输出还未完成的工作
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+//关闭阶段的默认超时
+const (
+	DefaultShutdownHTTPTimeout   = 30 * time.Second
+	DefaultShutdownWorkerTimeout = 30 * time.Second
+	DefaultShutdownCloseTimeout  = 10 * time.Second
+)
+
+//works 有名字的工作，用于关闭超时的时候输出未完成的工作
+type works struct {
+	mu   *sync.Mutex
+	seq  uint64
+	list map[uint64]string
+	wg   *sync.WaitGroup
+}
+
+func newWorks() *works {
+	return &works{
+		mu:   new(sync.Mutex),
+		list: make(map[uint64]string),
+		wg:   new(sync.WaitGroup),
+	}
+}
+
+//返回的done必须调用，多次调用只生效一次
+func (w *works) add(name string) (done func()) {
+	w.mu.Lock()
+	w.seq++
+	id := w.seq
+	w.list[id] = name
+	w.wg.Add(1)
+	w.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			delete(w.list, id)
+			w.mu.Unlock()
+			w.wg.Done()
+		})
+	}
+}
+
+//未完成的工作，同名的合并计数
+func (w *works) outstanding() (list []string) {
+	w.mu.Lock()
+	counts := make(map[string]int)
+	for _, name := range w.list {
+		counts[name]++
+	}
+	w.mu.Unlock()
+
+	for name, n := range counts {
+		if n > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, n)
+		}
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return
+}
+
+type closer struct {
+	name string
+	f    func() error
+}
+
+//AddWork 注册有名字的后台工作，关闭时会等待，超时的时候会输出名字
+//用法：defer ctx.AddWork("consumer order")()
+func (ctx *SignalContext) AddWork(name string) (done func()) {
+	return ctx.bgWorks.add(name)
+}
+
+//OnClose 注册关闭的最后阶段执行的函数，用于关闭连接池等，按注册的相反顺序执行
+func (ctx *SignalContext) OnClose(name string, f func() error) {
+	ctx.closeMu.Lock()
+	defer ctx.closeMu.Unlock()
+	ctx.closers = append(ctx.closers, closer{name: name, f: f})
+}
+
+func (ctx *SignalContext) shutdown(c configs.ShutdownConfig) {
+	httpTimeout := getShutdownTimeout(c.HTTPTimeout, DefaultShutdownHTTPTimeout)
+	workerTimeout := getShutdownTimeout(c.WorkerTimeout, DefaultShutdownWorkerTimeout)
+	closeTimeout := getShutdownTimeout(c.CloseTimeout, DefaultShutdownCloseTimeout)
+
+	logger.Info("shutdown phase 1/4: stop accepting new requests")
+	atomic.StoreInt32(&ctx.closing, 1)
+
+	logger.Infof("shutdown phase 2/4: wait in-flight http and grpc requests, timeout %s", httpTimeout)
+	if !waitTimeout(ctx.httpWorks.wg, httpTimeout) {
+		logger.Warnf("shutdown phase 2/4 timeout, outstanding requests: %v", ctx.httpWorks.outstanding())
+	}
+
+	logger.Infof("shutdown phase 3/4: notify background works, timeout %s", workerTimeout)
+	ctx.Cancel()
+	allWg := new(sync.WaitGroup)
+	allWg.Add(3)
+	//组件按顺序停止，每个组件有自己的StopTimeout，整体不超过workerTimeout
+	compWorks := newWorks()
+	go func() {
+		defer allWg.Done()
+		ctx.stopComponents(compWorks)
+	}()
+	go func() {
+		defer allWg.Done()
+		ctx.bgWorks.wg.Wait()
+	}()
+	go func() {
+		defer allWg.Done()
+		ctx.Wg.Wait()
+	}()
+	if !waitTimeout(allWg, workerTimeout) {
+		outstanding := append(compWorks.outstanding(), ctx.bgWorks.outstanding()...)
+		if n := atomic.LoadInt64(&ctx.anonymous); n > 0 {
+			outstanding = append(outstanding, fmt.Sprintf("%d works registered by WgAdd", n))
+		}
+		logger.Warnf("shutdown phase 3/4 timeout, outstanding works: %v", outstanding)
+	}
+
+	logger.Infof("shutdown phase 4/4: close pools, timeout %s", closeTimeout)
+	ctx.closeMu.Lock()
+	closers := ctx.closers
+	ctx.closeMu.Unlock()
+	closeWorks := newWorks()
+	dones := make([]func(), len(closers))
+	for i, c := range closers {
+		dones[i] = closeWorks.add(c.name)
+	}
+	go func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].f(); err != nil {
+				logger.Warnf("close %s failed: %v", closers[i].name, err)
+			}
+			dones[i]()
+		}
+	}()
+	if !waitTimeout(closeWorks.wg, closeTimeout) {
+		logger.Warnf("shutdown phase 4/4 timeout, not closed: %v", closeWorks.outstanding())
+	}
+}
+
+//IsClosing 是否已经开始关闭，开始关闭后不再接收新请求
+func (ctx *SignalContext) IsClosing() bool {
+	return atomic.LoadInt32(&ctx.closing) == 1
+}
+
+//配置单位是秒
+func getShutdownTimeout(t, def time.Duration) time.Duration {
+	if t <= 0 {
+		return def
+	}
+
+	return t * time.Second
+}
+
+//完成返回true，超时返回false
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	c := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(c)
+	}()
+	select {
+	case <-c:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package hfw
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hsyan2008/hfw2/configs"
+)
+
+type orderRecorder struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (r *orderRecorder) add(s string) {
+	r.mu.Lock()
+	r.list = append(r.list, s)
+	r.mu.Unlock()
+}
+
+func (r *orderRecorder) index(s string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, v := range r.list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestShutdownPhases(t *testing.T) {
+	ctx := newSignalContext()
+	rec := new(orderRecorder)
+
+	_ = ctx.RegisterComponent(Component{
+		Name: "consumer",
+		Run: func(c context.Context) error {
+			<-c.Done()
+			rec.add("component")
+			return nil
+		},
+	})
+	if err := ctx.startComponents(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := ctx.httpWorks.add("GET /slow")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if !ctx.IsClosing() {
+			t.Error("should be closing while waiting http requests")
+		}
+		if ctx.Ctx.Err() != nil {
+			t.Error("Ctx should not be canceled before http requests finished")
+		}
+		rec.add("http")
+		done()
+	}()
+
+	workDone := ctx.AddWork("worker")
+	go func() {
+		<-ctx.Ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		rec.add("worker")
+		workDone()
+	}()
+
+	ctx.OnClose("first", func() error {
+		rec.add("close first")
+		return nil
+	})
+	ctx.OnClose("second", func() error {
+		rec.add("close second")
+		return nil
+	})
+
+	ctx.shutdown(configs.ShutdownConfig{})
+
+	for _, v := range []string{"http", "component", "worker", "close second", "close first"} {
+		if rec.index(v) < 0 {
+			t.Fatalf("%s not done, got %v", v, rec.list)
+		}
+	}
+	if rec.index("http") > rec.index("component") || rec.index("http") > rec.index("worker") {
+		t.Fatalf("http requests should be finished before background works, got %v", rec.list)
+	}
+	if rec.index("worker") > rec.index("close second") || rec.index("component") > rec.index("close second") {
+		t.Fatalf("background works should be finished before close, got %v", rec.list)
+	}
+	if rec.index("close second") > rec.index("close first") {
+		t.Fatalf("closers should run in reverse order, got %v", rec.list)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctx := newSignalContext()
+
+	block := make(chan struct{})
+	defer close(block)
+	//组件自己的StopTimeout比WorkerTimeout长，不能拖长关闭时间
+	_ = ctx.RegisterComponent(Component{
+		Name:        "slow",
+		StopTimeout: time.Minute,
+		OnStop: func(c context.Context) error {
+			<-block
+			return nil
+		},
+	})
+	if err := ctx.startComponents(); err != nil {
+		t.Fatal(err)
+	}
+	_ = ctx.httpWorks.add("GET /hang")
+	_ = ctx.AddWork("hang")
+	ctx.WgAdd()
+	ctx.OnClose("hang", func() error {
+		<-block
+		return nil
+	})
+
+	start := time.Now()
+	ctx.shutdown(configs.ShutdownConfig{HTTPTimeout: 1, WorkerTimeout: 1, CloseTimeout: 1})
+	if cost := time.Since(start); cost > 3500*time.Millisecond {
+		t.Fatalf("shutdown should be bounded by phase timeouts, cost %s", cost)
+	}
+	if ctx.Ctx.Err() == nil {
+		t.Fatal("Ctx should be canceled")
+	}
+	if list := ctx.bgWorks.outstanding(); len(list) != 1 || list[0] != "hang" {
+		t.Fatalf("outstanding works want [hang], got %v", list)
+	}
+}
+
+func TestWorksOutstanding(t *testing.T) {
+	w := newWorks()
+	d1 := w.add("consumer")
+	d2 := w.add("consumer")
+	d3 := w.add("cron sync")
+	if list := w.outstanding(); strings.Join(list, ",") != "consumer (x2),cron sync" {
+		t.Fatalf("got %v", list)
+	}
+	d1()
+	d1()
+	d3()
+	if list := w.outstanding(); strings.Join(list, ",") != "consumer" {
+		t.Fatalf("got %v", list)
+	}
+	d2()
+	if !waitTimeout(w.wg, time.Second) {
+		t.Fatal("all works done, wait should return")
+	}
+}
@@ -1,8 +1,9 @@
 // 信号处理
 //kill -INT pid 终止
 //kill -TERM pid 重启
-//需要调用Wg.Add()
+//需要调用Wg.Add()或者AddWork
 //需要监听Shutdown通道
+//关闭的各个阶段见shutdown.go
 package hfw
 
 import (
@@ -10,8 +11,8 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
-	"time"
 
 	logger "github.com/hsyan2008/go-logger"
 )
@@ -26,6 +27,25 @@ type SignalContext struct {
 	mu    *sync.Mutex
 	doing bool
 
+	components *componentRegistry
+
+	//处理中的http和grpc请求
+	httpWorks *works
+	//AddWork注册的后台工作
+	bgWorks *works
+	//WgAdd注册的数量
+	anonymous int64
+	//开始关闭后为1，见shutdown.go
+	closing int32
+
+	closeMu *sync.Mutex
+	closers []closer
+
+	//Server.Upgrade开启时设置，见upgrade.go
+	httpMu   *sync.Mutex
+	upgrade  func() error
+	stopHTTP func()
+
 	//Shutdown 业务方手动监听此通道获知通知
 	Ctx    context.Context    `json:"-"`
 	Cancel context.CancelFunc `json:"-"`
@@ -34,12 +54,24 @@ type SignalContext struct {
 var signalContext *SignalContext
 
 func init() {
-	signalContext = &SignalContext{
+	signalContext = newSignalContext()
+}
+
+func newSignalContext() *SignalContext {
+	ctx := &SignalContext{
 		Wg:   new(sync.WaitGroup),
 		done: make(chan bool),
 		mu:   new(sync.Mutex),
+
+		components: newComponentRegistry(),
+		httpWorks:  newWorks(),
+		bgWorks:    newWorks(),
+		closeMu:    new(sync.Mutex),
+		httpMu:     new(sync.Mutex),
 	}
-	signalContext.Ctx, signalContext.Cancel = context.WithCancel(context.Background())
+	ctx.Ctx, ctx.Cancel = context.WithCancel(context.Background())
+
+	return ctx
 }
 
 //GetSignalContext 一般用于其他包或者非http程序
@@ -55,8 +87,24 @@ func (ctx *SignalContext) listenSignal() {
 	logger.Infof("Exec `kill -INT %d` will graceful exit", PID)
 	logger.Infof("Exec `kill -TERM %d` will graceful restart", PID)
 
-	s := <-c
-	logger.Info("recv signal:", s)
+	var s os.Signal
+	for {
+		s = <-c
+		logger.Info("recv signal:", s)
+		ctx.httpMu.Lock()
+		upgrade, stopHTTP := ctx.upgrade, ctx.stopHTTP
+		ctx.httpMu.Unlock()
+		if upgrade != nil && (s == syscall.SIGHUP || s == syscall.SIGTERM) {
+			if err := upgrade(); err != nil {
+				logger.Errorf("upgrade failed, continue serving: %v", err)
+				continue
+			}
+		}
+		if stopHTTP != nil {
+			go stopHTTP()
+		}
+		break
+	}
 	go ctx.doShutdownDone()
 	if ctx.IsHTTP {
 		logger.Info("Stopping http server")
@@ -77,6 +125,13 @@ func (ctx *SignalContext) listenSignal() {
 	}
 }
 
+func (ctx *SignalContext) setHTTPServer(upgrade func() error, stopHTTP func()) {
+	ctx.httpMu.Lock()
+	defer ctx.httpMu.Unlock()
+	ctx.upgrade = upgrade
+	ctx.stopHTTP = stopHTTP
+}
+
 func (ctx *SignalContext) doShutdownDone() {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
@@ -88,28 +143,9 @@ func (ctx *SignalContext) doShutdownDone() {
 	logger.Info("doShutdownDone start.")
 	defer logger.Info("doShutdownDone done.")
 
-	go ctx.waitDone()
-
-	timeout := 30
-	select {
-	case <-time.After(time.Duration(timeout) * time.Second):
-		logger.Warnf("doShutdownDone %ds timeout", timeout)
-		close(ctx.done)
-	case <-ctx.done:
-	}
-}
-
-//通知业务方，并等待业务方结束
-func (ctx *SignalContext) waitDone() {
-	//context包来取消，以通知业务方
-	logger.Info("signal ctx cancel")
-	ctx.Cancel()
-	//等待业务方完成退出
-	logger.Info("signal ctx waitgroup wait done start")
-	ctx.WgWait()
 	//表示全部完成
-	logger.Info("signal ctx waitgroup wait done end")
-	close(ctx.done)
+	defer close(ctx.done)
+	ctx.shutdown(GetConfig().Shutdown)
 }
 
 //Shutdowned 获取是否已经全部结束，暂时只有run.go里用到
@@ -119,10 +155,12 @@ func (ctx *SignalContext) Shutdowned() {
 }
 
 func (ctx *SignalContext) WgAdd() {
+	atomic.AddInt64(&ctx.anonymous, 1)
 	ctx.Wg.Add(1)
 }
 
 func (ctx *SignalContext) WgDone() {
+	atomic.AddInt64(&ctx.anonymous, -1)
 	ctx.Wg.Done()
 }
 
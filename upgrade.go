@@ -0,0 +1,103 @@
+package hfw
+
+//Server.Upgrade开启后，kill -TERM或者-HUP的时候启动新进程并传递监听
+//新进程启动完成并通过所有就绪检查后通知旧进程，旧进程再停止接收请求并退出
+//新进程在Server.UpgradeTimeout内没有就绪，旧进程杀掉新进程继续服务
+//就绪检查用法
+//hfw.AddReadinessCheck("db", func(ctx context.Context) error { return engine.Ping() })
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/serve"
+)
+
+//DefaultUpgradeTimeout 等待新进程就绪的默认时间
+const DefaultUpgradeTimeout = 30 * time.Second
+
+type readinessCheck struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+var readinessChecks = struct {
+	l    *sync.RWMutex
+	list []readinessCheck
+}{
+	l: new(sync.RWMutex),
+}
+
+//AddReadinessCheck 添加就绪检查，升级的时候新进程全部检查通过才算就绪
+func AddReadinessCheck(name string, f func(ctx context.Context) error) {
+	readinessChecks.l.Lock()
+	defer readinessChecks.l.Unlock()
+	readinessChecks.list = append(readinessChecks.list, readinessCheck{name: name, f: f})
+}
+
+//CheckReadiness 依次执行就绪检查，返回第一个错误
+func CheckReadiness(ctx context.Context) error {
+	readinessChecks.l.RLock()
+	list := readinessChecks.list
+	readinessChecks.l.RUnlock()
+	for _, c := range list {
+		if err := c.f(ctx); err != nil {
+			return fmt.Errorf("readiness check %s failed: %v", c.name, err)
+		}
+	}
+
+	return nil
+}
+
+//可以交接的http服务，升级失败的时候继续服务
+func serveUpgradable() (err error) {
+	s, err := serve.NewServer(Config)
+	if err != nil {
+		return
+	}
+
+	timeout := DefaultUpgradeTimeout
+	if t := GetConfig().Server.UpgradeTimeout; t > 0 {
+		timeout = t * time.Second
+	}
+	signalContext.setHTTPServer(func() error {
+		pid, err := s.Upgrade(timeout)
+		if err == nil {
+			logger.Infof("new process %d is ready", pid)
+		}
+		return err
+	}, func() {
+		if err := s.Shutdown(context.Background()); err != nil {
+			logger.Warnf("http server shutdown error: %v", err)
+		}
+	})
+
+	if serve.IsUpgradeChild() {
+		go notifyReady()
+	}
+
+	return s.Serve()
+}
+
+//新进程每秒检查一次，直到通过或者被旧进程杀掉
+func notifyReady() {
+	for {
+		err := CheckReadiness(signalContext.Ctx)
+		if err == nil {
+			break
+		}
+		logger.Warn(err)
+		select {
+		case <-signalContext.Ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	if err := serve.NotifyReady(); err != nil {
+		logger.Errorf("notify ready failed: %v", err)
+		return
+	}
+	logger.Info("ready, notified old process")
+}
@@ -0,0 +1,249 @@
+package hfw
+
+//非http的常驻任务，和http服务可以在同一个程序里运行，见-mode参数
+//用法
+//hfw.RegisterWorker("consumer", func(ctx context.Context) error {
+//	for {
+//		select {
+//		case <-ctx.Done():
+//			return nil
+//		case msg := <-ch:
+//			...
+//		}
+//	}
+//}, hfw.WorkerOptions{Concurrency: 4})
+//panic或者返回错误后按退避时间重启，返回nil表示正常结束，不再重启
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/hsyan2008/go-logger"
+	"github.com/hsyan2008/hfw2/encoding"
+)
+
+//运行模式
+const (
+	RunModeAll    = "all"
+	RunModeHTTP   = "http"
+	RunModeWorker = "worker"
+)
+
+//worker重启的默认退避时间
+const (
+	DefaultWorkerMinBackoff = time.Second
+	DefaultWorkerMaxBackoff = time.Minute
+)
+
+//worker的状态
+const (
+	WorkerStateRunning  = "running"
+	WorkerStateBackoff  = "backoff"
+	WorkerStateFinished = "finished"
+	WorkerStateFailed   = "failed"
+)
+
+var runMode string
+
+//WorkerOptions worker的设置
+type WorkerOptions struct {
+	//同时运行的个数，默认1
+	Concurrency int
+	//出错后不重启
+	NoRestart bool
+	//重启的退避时间，每次失败翻倍，不超过MaxBackoff
+	//运行超过MaxBackoff后失败的，从MinBackoff重新开始
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+//WorkerStatus worker每个实例的状态
+type WorkerStatus struct {
+	Name      string    `json:"name"`
+	Index     int       `json:"index"`
+	State     string    `json:"state"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type worker struct {
+	name string
+	f    func(ctx context.Context) error
+	opt  WorkerOptions
+	//每个实例一个
+	status []*WorkerStatus
+}
+
+var workers = struct {
+	l         *sync.RWMutex
+	list      []*worker
+	isStarted bool
+}{
+	l: new(sync.RWMutex),
+}
+
+//RegisterWorker 注册worker，必须在Run之前调用
+func RegisterWorker(name string, f func(ctx context.Context) error, opt WorkerOptions) error {
+	if len(name) == 0 {
+		return errors.New("worker name is empty")
+	}
+	if f == nil {
+		return fmt.Errorf("worker %s func is nil", name)
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 1
+	}
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = DefaultWorkerMinBackoff
+	}
+	if opt.MaxBackoff < opt.MinBackoff {
+		opt.MaxBackoff = DefaultWorkerMaxBackoff
+		if opt.MaxBackoff < opt.MinBackoff {
+			opt.MaxBackoff = opt.MinBackoff
+		}
+	}
+
+	workers.l.Lock()
+	defer workers.l.Unlock()
+	if workers.isStarted {
+		return fmt.Errorf("worker %s register after started", name)
+	}
+	for _, w := range workers.list {
+		if w.name == name {
+			return fmt.Errorf("worker %s has registered", name)
+		}
+	}
+	w := &worker{name: name, f: f, opt: opt}
+	for i := 0; i < opt.Concurrency; i++ {
+		w.status = append(w.status, &WorkerStatus{Name: name, Index: i})
+	}
+	workers.list = append(workers.list, w)
+
+	return nil
+}
+
+//GetWorkerStatus 返回所有worker实例的状态，按名字排序
+func GetWorkerStatus() (list []WorkerStatus) {
+	workers.l.RLock()
+	defer workers.l.RUnlock()
+	for _, w := range workers.list {
+		for _, s := range w.status {
+			list = append(list, *s)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return
+}
+
+func hasWorkers() bool {
+	workers.l.RLock()
+	defer workers.l.RUnlock()
+	return len(workers.list) > 0
+}
+
+//启动所有worker，关闭的第3阶段ctx被取消后等待退出
+func startWorkers(ctx *SignalContext) {
+	workers.l.Lock()
+	defer workers.l.Unlock()
+	if workers.isStarted {
+		return
+	}
+	workers.isStarted = true
+	for _, w := range workers.list {
+		for i := range w.status {
+			done := ctx.AddWork(fmt.Sprintf("worker %s#%d", w.name, i))
+			go func(w *worker, i int) {
+				defer done()
+				w.loop(ctx.Ctx, i)
+			}(w, i)
+		}
+	}
+}
+
+func (w *worker) loop(ctx context.Context, i int) {
+	backoff := w.opt.MinBackoff
+	for {
+		w.setStatus(i, func(s *WorkerStatus) {
+			s.State = WorkerStateRunning
+			s.StartedAt = time.Now()
+		})
+		logger.Infof("worker %s#%d start", w.name, i)
+		startedAt := time.Now()
+		err := w.run(ctx)
+		if err == nil || ctx.Err() != nil {
+			logger.Infof("worker %s#%d finished", w.name, i)
+			w.setStatus(i, func(s *WorkerStatus) {
+				s.State = WorkerStateFinished
+			})
+			return
+		}
+		logger.Errorf("worker %s#%d failed: %v", w.name, i, err)
+		if w.opt.NoRestart {
+			w.setStatus(i, func(s *WorkerStatus) {
+				s.State = WorkerStateFailed
+				s.LastError = err.Error()
+			})
+			return
+		}
+
+		if time.Since(startedAt) > w.opt.MaxBackoff {
+			backoff = w.opt.MinBackoff
+		}
+		w.setStatus(i, func(s *WorkerStatus) {
+			s.State = WorkerStateBackoff
+			s.LastError = err.Error()
+			s.Restarts++
+		})
+		logger.Infof("worker %s#%d restart after %s", w.name, i, backoff)
+		select {
+		case <-ctx.Done():
+			w.setStatus(i, func(s *WorkerStatus) {
+				s.State = WorkerStateFinished
+			})
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > w.opt.MaxBackoff {
+			backoff = w.opt.MaxBackoff
+		}
+	}
+}
+
+//panic转为错误
+func (w *worker) run(ctx context.Context) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			logger.Errorf("worker %s panic: %v\n%s", w.name, e, debug.Stack())
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+
+	return w.f(ctx)
+}
+
+func (w *worker) setStatus(i int, f func(s *WorkerStatus)) {
+	workers.l.Lock()
+	defer workers.l.Unlock()
+	f(w.status[i])
+}
+
+//管理接口，见admin.go
+func workerStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b, err := encoding.JSON.Marshal(GetWorkerStatus())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,69 @@
+package xorm
+
+//引入本包后注册以下命令
+//  ./app -e dev gen models [-dir models] [-tables user,order] [-prefix cos_] [-dry-run]
+//用配置里的Db连接数据库，读取表结构生成model
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	hfw "github.com/hsyan2008/hfw2"
+	"github.com/hsyan2008/hfw2/db"
+)
+
+func init() {
+	var (
+		dir, pkg, tables, prefix, tpl string
+		dryRun                        bool
+	)
+	_ = hfw.RegisterCommand(hfw.Command{
+		Name:  "gen models",
+		Short: "generate models from database tables",
+		Usage: "[flags]",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&dir, "dir", filepath.Join(hfw.APPPATH, "models"), "output dir")
+			fs.StringVar(&pkg, "package", "", "package name, default is the dir name")
+			fs.StringVar(&tables, "tables", "", "comma separated table names, default all tables")
+			fs.StringVar(&prefix, "prefix", "", "table name prefix to strip from struct names")
+			fs.StringVar(&tpl, "template", "", "template file, default is the built-in struct.go.tpl")
+			fs.BoolVar(&dryRun, "dry-run", false, "print diff instead of writing files")
+		},
+		Run: func(args []string) error {
+			if hfw.Config.Db.Driver == "" {
+				return errors.New("nil db config")
+			}
+			engine, err := db.InitDb(hfw.Config, hfw.Config.Db)
+			if err != nil {
+				return err
+			}
+
+			g := NewGenerator(engine, dir)
+			g.Package, g.Prefix, g.DryRun = pkg, prefix, dryRun
+			for _, t := range strings.Split(tables, ",") {
+				if t = strings.TrimSpace(t); len(t) > 0 {
+					g.Tables = append(g.Tables, t)
+				}
+			}
+			if len(tpl) > 0 {
+				b, err := ioutil.ReadFile(tpl)
+				if err != nil {
+					return err
+				}
+				g.Template = string(b)
+			}
+
+			changed, err := g.Run()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				fmt.Printf("%d files would be changed\n", len(changed))
+			}
+			return nil
+		},
+	})
+}
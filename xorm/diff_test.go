@@ -0,0 +1,32 @@
+package xorm
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := splitLines("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n")
+	b := splitLines("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n")
+	expect := `--- x.go
++++ x.go
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+`
+	if s := unifiedDiff("x.go", a, b); s != expect {
+		t.Fatalf("diff:\n%s\nexpect:\n%s", s, expect)
+	}
+	if s := unifiedDiff("x.go", a, a); s != "" {
+		t.Fatalf("same lines diff: %s", s)
+	}
+	if s := unifiedDiff("x.go", nil, []string{"a"}); s != "--- x.go\n+++ x.go\n@@ -0,0 +1,1 @@\n+a\n" {
+		t.Fatalf("new file diff: %s", s)
+	}
+}
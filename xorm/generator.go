@@ -0,0 +1,267 @@
+//Package xorm 按数据库的表用struct.go.tpl生成model
+//引入本包后可以用命令生成，见command.go
+//  ./app -e dev gen models -tables user,order -dry-run
+//每个表生成一个 表名_gen.go，重新生成会覆盖，自己的代码写在其他文件里，如user.go
+package xorm
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-xorm/core"
+	"github.com/go-xorm/xorm"
+)
+
+//StructTemplate 默认的model模板
+//go:embed struct.go.tpl
+var StructTemplate string
+
+//GeneratedHeader 生成的文件第一行，没有这行的文件不会被覆盖
+const GeneratedHeader = "// Code generated by hfw gen models. DO NOT EDIT."
+
+//Generator 生成model
+type Generator struct {
+	engine xorm.EngineInterface
+	//输出目录
+	Dir string
+	//包名，默认是目录名
+	Package string
+	//表名前缀，生成struct名的时候去掉
+	Prefix string
+	//要生成的表，空表示所有表
+	Tables []string
+	//模板内容，默认StructTemplate
+	Template string
+	//不写文件，输出和现有文件的diff
+	DryRun bool
+	//默认os.Stdout
+	Out io.Writer
+}
+
+//NewGenerator engine一般是db.InitDb返回的
+func NewGenerator(engine xorm.EngineInterface, dir string) *Generator {
+	return &Generator{
+		engine:   engine,
+		Dir:      dir,
+		Template: StructTemplate,
+		Out:      os.Stdout,
+	}
+}
+
+//Run 生成所有表的model，返回有变化的文件
+func (g *Generator) Run() (changed []string, err error) {
+	tables, err := g.getTables()
+	if err != nil {
+		return
+	}
+	if len(g.Package) == 0 {
+		abs, err := filepath.Abs(g.Dir)
+		if err != nil {
+			return nil, err
+		}
+		g.Package = filepath.Base(abs)
+	}
+
+	//core.PrefixMapper要求所有名字都有前缀，列名没有
+	mapper := func(name string) string {
+		return core.SnakeMapper{}.Table2Obj(strings.TrimPrefix(name, g.Prefix))
+	}
+	tpl, err := template.New("model").Funcs(template.FuncMap{
+		"Mapper": mapper,
+		"Type":   typeString,
+		"Tag":    g.tag,
+	}).Parse(g.Template)
+	if err != nil {
+		return
+	}
+
+	if !g.DryRun {
+		if err = os.MkdirAll(g.Dir, 0755); err != nil {
+			return
+		}
+	}
+	for _, table := range tables {
+		file := filepath.Join(g.Dir, strings.TrimPrefix(table.Name, g.Prefix)+"_gen.go")
+		content, err := g.render(tpl, table)
+		if err != nil {
+			return changed, fmt.Errorf("table %s: %v", table.Name, err)
+		}
+		ok, err := g.write(file, content)
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed = append(changed, file)
+		}
+	}
+
+	return
+}
+
+//按名字过滤并排序
+func (g *Generator) getTables() (tables []*core.Table, err error) {
+	all, err := g.engine.DBMetas()
+	if err != nil {
+		return
+	}
+	byName := make(map[string]*core.Table, len(all))
+	for _, t := range all {
+		byName[t.Name] = t
+	}
+	if len(g.Tables) == 0 {
+		tables = all
+	} else {
+		for _, name := range g.Tables {
+			t, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("table %s not found", name)
+			}
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return nil, errors.New("no table found")
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+
+	return
+}
+
+func (g *Generator) render(tpl *template.Template, table *core.Table) ([]byte, error) {
+	var imports []string
+	for _, col := range table.Columns() {
+		if typeString(col) == "time.Time" {
+			imports = append(imports, "time")
+			break
+		}
+	}
+
+	buf := bytes.NewBufferString(GeneratedHeader + "\n\n")
+	err := tpl.Execute(buf, map[string]interface{}{
+		"Models":  g.Package,
+		"Imports": imports,
+		"Tables":  []*core.Table{table},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+//写文件，DryRun的时候输出diff，返回是否有变化
+func (g *Generator) write(file string, content []byte) (changed bool, err error) {
+	old, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	err = nil
+	if len(old) > 0 && !bytes.HasPrefix(old, []byte(GeneratedHeader)) {
+		return false, fmt.Errorf("%s is not generated, refuse to overwrite", file)
+	}
+	if bytes.Equal(old, content) {
+		fmt.Fprintf(g.Out, "%s unchanged\n", file)
+		return
+	}
+
+	if g.DryRun {
+		fmt.Fprint(g.Out, unifiedDiff(file, splitLines(string(old)), splitLines(string(content))))
+		return true, nil
+	}
+	if err = ioutil.WriteFile(file, content, 0644); err != nil {
+		return
+	}
+	fmt.Fprintf(g.Out, "%s generated\n", file)
+
+	return true, nil
+}
+
+//列对应的go类型
+func typeString(col *core.Column) string {
+	s := core.SQLType2Type(col.SQLType).String()
+	if s == "[]uint8" {
+		return "[]byte"
+	}
+
+	return s
+}
+
+var tagReplacer = strings.NewReplacer("`", "", `"`, "", "'", "")
+
+//列对应的tag，和xorm reverse生成的一致
+func (g *Generator) tag(table *core.Table, col *core.Column) string {
+	var res []string
+	if !col.Nullable && !(col.IsPrimaryKey && col.IsAutoIncrement) {
+		res = append(res, "not null")
+	}
+	if col.IsPrimaryKey {
+		res = append(res, "pk")
+	}
+	if col.Default != "" {
+		res = append(res, "default "+col.Default)
+	}
+	if col.IsAutoIncrement {
+		res = append(res, "autoincr")
+	}
+	if col.Comment != "" && g.engine.Dialect().DBType() == core.MYSQL {
+		res = append(res, fmt.Sprintf("comment('%s')", tagReplacer.Replace(col.Comment)))
+	}
+
+	names := make([]string, 0, len(col.Indexes))
+	for name := range col.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		index, ok := table.Indexes[name]
+		if !ok {
+			continue
+		}
+		str := "index"
+		if index.Type == core.UniqueType {
+			str = "unique"
+		}
+		if len(index.Cols) > 1 {
+			str += "(" + index.Name + ")"
+		}
+		res = append(res, str)
+	}
+
+	typ := col.SQLType.Name
+	if col.Length2 != 0 {
+		typ += fmt.Sprintf("(%d,%d)", col.Length, col.Length2)
+	} else if col.Length != 0 {
+		typ += fmt.Sprintf("(%d)", col.Length)
+	} else if len(col.EnumOptions) > 0 {
+		typ += "(" + joinOptions(col.EnumOptions) + ")"
+	} else if len(col.SetOptions) > 0 {
+		typ += "(" + joinOptions(col.SetOptions) + ")"
+	}
+	res = append(res, typ)
+
+	return fmt.Sprintf("`json:\"%s\" xorm:\"%s\"`", col.Name, strings.Join(res, " "))
+}
+
+//enum和set的选项按定义的顺序
+func joinOptions(options map[string]int) string {
+	list := make([]string, len(options))
+	for k, v := range options {
+		if v < len(list) {
+			list[v] = "'" + tagReplacer.Replace(k) + "'"
+		}
+	}
+
+	return strings.Join(list, ",")
+}
@@ -1,13 +1,12 @@
 package {{.Models}}
 
 import (
+    "context"
     "encoding/gob"
     "errors"
     "fmt"
     "database/sql"
-{{$ilen := len .Imports}}
-{{if gt $ilen 0}}
-{{range .Imports}}"{{.}}"{{end}}
+{{range .Imports}}    "{{.}}"
 {{end}}
     "github.com/go-xorm/xorm"
     hfw "github.com/hsyan2008/hfw2"
@@ -34,8 +33,8 @@ type {{Mapper .Name}} struct {
     db.Models `xorm:"extends"`
 	Dao *db.XormDao `json:"-" xorm:"-"`
 {{$table := .}}
-{{range .ColumnsSeq}}{{$col := $table.GetColumn .}}	{{if eq $col.Name "id" "is_deleted" "updated_at" "created_at"}}{{else}}{{Mapper $col.Name}}	{{Type $col}} {{Tag $table $col}}{{end}}
-{{end}}
+{{- range .ColumnsSeq}}{{$col := $table.GetColumn .}}{{if eq $col.Name "id" "is_deleted" "updated_at" "created_at"}}{{else}}
+	{{Mapper $col.Name}}	{{Type $col}} {{Tag $table $col}}{{end}}{{end}}
 }
 
 {{range .ColumnsSeq}}{{$col := $table.GetColumn .}}
@@ -151,7 +150,7 @@ func (m *{{Mapper .Name}}) GetById(id interface{}) (t *{{Mapper .Name}}, err err
 
 func (m *{{Mapper .Name}}) Replace(cond db.Cond) (int64, error) {
 	defer m.Dao.ClearCache(m)
-    return m.Dao.Replace(fmt.Sprintf("REPLACE `%s` SET ", m.TableName()), cond)
+    return m.Dao.Replace(fmt.Sprintf("REPLACE %s SET ", m.Dao.Quote(m.TableName())), cond)
 }
 
 func (m *{{Mapper .Name}}) Exec(sqlState string, args ...interface{}) (sql.Result, error) {
@@ -171,6 +170,103 @@ func (m *{{Mapper .Name}}) QueryInterface(args ...interface{}) ([]map[string]int
 	return m.Dao.QueryInterface(args...)
 }
 
+//WithContext 返回使用ctx执行sql的model，数据和原model相同，如
+//{{Mapper .Name}}Model.WithContext(httpCtx.Ctx).Search(cond)
+//配置了Db.ReadYourWrites的时候，用httpCtx.Ctx写了之后，同一个请求的读走主库
+//没有用WithContext的写记录在这个model的dao上，之后没有用WithContext的读也走主库
+func (m *{{Mapper .Name}}) WithContext(ctx context.Context) *{{Mapper .Name}} {
+    if m.Dao == nil {
+        panic("dao not init")
+    }
+    n := *m
+    n.Dao = m.Dao.WithContext(ctx)
+    return &n
+}
+
+//Master 返回读走主库的model，有从库的时候用于刚写完马上要读，如
+//{{Mapper .Name}}Model.Master().SearchOne(cond)
+func (m *{{Mapper .Name}}) Master() *{{Mapper .Name}} {
+    if m.Dao == nil {
+        panic("dao not init")
+    }
+    n := *m
+    n.Dao = m.Dao.Master()
+    return &n
+}
+
+func (m *{{Mapper .Name}}) SaveContext(ctx context.Context, t ...*{{Mapper .Name}}) (affected int64, err error) {
+    //WithContext返回的是副本，需要把m传进去，Insert后id才会设置到m
+    if len(t) == 0 {
+        t = []*{{Mapper .Name}}{m}
+    }
+    return m.WithContext(ctx).Save(t...)
+}
+
+func (m *{{Mapper .Name}}) SavesContext(ctx context.Context, t []*{{Mapper .Name}}) (affected int64, err error) {
+    return m.WithContext(ctx).Saves(t)
+}
+
+func (m *{{Mapper .Name}}) InsertContext(ctx context.Context, t ...*{{Mapper .Name}}) (affected int64, err error) {
+    //WithContext返回的是副本，需要把m传进去，Insert后id才会设置到m
+    if len(t) == 0 {
+        t = []*{{Mapper .Name}}{m}
+    }
+    return m.WithContext(ctx).Insert(t...)
+}
+
+func (m *{{Mapper .Name}}) UpdateContext(ctx context.Context, params db.Cond,
+	where db.Cond) (affected int64, err error) {
+	return m.WithContext(ctx).Update(params, where)
+}
+
+func (m *{{Mapper .Name}}) SearchOneContext(ctx context.Context, cond db.Cond) (t *{{Mapper .Name}}, err error) {
+	return m.WithContext(ctx).SearchOne(cond)
+}
+
+func (m *{{Mapper .Name}}) SearchContext(ctx context.Context, cond db.Cond) (t []*{{Mapper .Name}}, err error) {
+	return m.WithContext(ctx).Search(cond)
+}
+
+func (m *{{Mapper .Name}}) RowsContext(ctx context.Context, cond db.Cond) (rows *xorm.Rows, err error) {
+	return m.WithContext(ctx).Rows(cond)
+}
+
+func (m *{{Mapper .Name}}) IterateContext(ctx context.Context, cond db.Cond, f xorm.IterFunc) (err error) {
+	return m.WithContext(ctx).Iterate(cond, f)
+}
+
+func (m *{{Mapper .Name}}) CountContext(ctx context.Context, cond db.Cond) (total int64, err error) {
+	return m.WithContext(ctx).Count(cond)
+}
+
+func (m *{{Mapper .Name}}) GetMultiContext(ctx context.Context, ids ...interface{}) (t []*{{Mapper .Name}}, err error) {
+	return m.WithContext(ctx).GetMulti(ids...)
+}
+
+func (m *{{Mapper .Name}}) GetByIdContext(ctx context.Context, id interface{}) (t *{{Mapper .Name}}, err error) {
+	return m.WithContext(ctx).GetById(id)
+}
+
+func (m *{{Mapper .Name}}) ReplaceContext(ctx context.Context, cond db.Cond) (int64, error) {
+	return m.WithContext(ctx).Replace(cond)
+}
+
+func (m *{{Mapper .Name}}) ExecContext(ctx context.Context, sqlState string, args ...interface{}) (sql.Result, error) {
+	return m.WithContext(ctx).Exec(sqlState, args...)
+}
+
+func (m *{{Mapper .Name}}) QueryContext(ctx context.Context, args ...interface{}) ([]map[string][]byte, error) {
+	return m.WithContext(ctx).Query(args...)
+}
+
+func (m *{{Mapper .Name}}) QueryStringContext(ctx context.Context, args ...interface{}) ([]map[string]string, error) {
+	return m.WithContext(ctx).QueryString(args...)
+}
+
+func (m *{{Mapper .Name}}) QueryInterfaceContext(ctx context.Context, args ...interface{}) ([]map[string]interface{}, error) {
+	return m.WithContext(ctx).QueryInterface(args...)
+}
+
 //以下用于事务，注意同个实例不能在多个goroutine同时使用
 //使用完毕需要执行Close()，当Close的时候如果没有commit，会自动rollback
 //参数只能是0-1个，可以是
@@ -206,6 +302,12 @@ func New{{Mapper .Name}}(c ...interface{}) (m *{{Mapper .Name}}, err error) {
 	return
 }
 
+//Transaction 在事务里执行f，f返回nil提交，出错或者panic回滚，嵌套调用使用savepoint
+//f里用New{{Mapper .Name}}(tx)或者其他model的New方法加入同一个事务
+func (m *{{Mapper .Name}}) Transaction(ctx context.Context, f func(tx *db.XormDao) error) error {
+    return m.Dao.Transaction(ctx, f)
+}
+
 func (m *{{Mapper .Name}}) Close() {
     m.Dao.Close()
 }
@@ -214,6 +316,11 @@ func (m *{{Mapper .Name}}) Begin() error {
     return m.Dao.Begin()
 }
 
+//BeginContext ctx取消的时候事务自动回滚
+func (m *{{Mapper .Name}}) BeginContext(ctx context.Context) error {
+    return m.Dao.BeginContext(ctx)
+}
+
 func (m *{{Mapper .Name}}) Rollback() error {
     return m.Dao.Rollback()
 }